@@ -8,6 +8,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/dedalusj/cwmonitor/monitor"
 	"github.com/dedalusj/cwmonitor/util"
 	"gopkg.in/urfave/cli.v1"
@@ -28,19 +29,87 @@ func initLogger(c *cli.Context) {
 }
 
 func getConfig(c *cli.Context) monitor.Config {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	client := cloudwatch.New(sess)
+	// dry runs print the data instead of publishing it hence they do not need an AWS session
+	var client cloudwatchiface.CloudWatchAPI
+	if !c.Bool("dry-run") {
+		sess := session.Must(session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		}))
+		client = cloudwatch.New(sess)
+	}
 
 	return monitor.Config{
-		Namespace:   c.String("namespace"),
-		Interval:    time.Duration(c.Int("interval")) * time.Second,
-		HostId:      c.String("hostid"),
-		Metrics:     c.String("metrics"),
-		DockerLabel: c.String("metrics.dockerlabel"),
-		Once:        c.Bool("once"),
-		Client:      client,
+		Namespace:            c.String("namespace"),
+		Interval:             time.Duration(c.Int("interval")) * time.Second,
+		HostId:               c.String("hostid"),
+		Metrics:              c.String("metrics"),
+		Sinks:                c.String("sinks"),
+		DockerLabel:          c.String("metrics.dockerlabel"),
+		DockerAggregateLabel: c.String("metrics.dockeraggregatelabel"),
+		DockerConcurrency:    c.Int("metrics.dockerconcurrency"),
+		DockerTimeout:        time.Duration(c.Int("metrics.dockertimeout")) * time.Second,
+		DockerStream:         c.Bool("metrics.dockerstream"),
+		DockerHosts:          c.StringSlice("metrics.dockerhost"),
+		DockerAPIVersion:     c.String("metrics.dockerapiversion"),
+		DockerTLSCACert:      c.String("metrics.dockertlscacert"),
+		DockerTLSCert:        c.String("metrics.dockertlscert"),
+		DockerTLSKey:         c.String("metrics.dockertlskey"),
+		DockerTLSVerify:      c.Bool("metrics.dockertlsverify"),
+		CgroupRoot:           c.String("metrics.cgrouproot"),
+		CgroupRuntimeRoot:    c.String("metrics.cgroupruntimeroot"),
+		KubeletURL:           c.String("metrics.kubeleturl"),
+		KubeletTokenFile:     c.String("metrics.kubelettokenfile"),
+		KubeletInsecure:      c.Bool("metrics.kubeletinsecure"),
+		HTTPChecks:           c.StringSlice("metrics.httpcheck"),
+		TCPChecks:            c.StringSlice("metrics.tcpcheck"),
+		DNSChecks:            c.StringSlice("metrics.dnscheck"),
+		DNSResolver:          c.String("metrics.dnsresolver"),
+		ExecCommands:         c.StringSlice("metrics.exec"),
+		ExecTimeout:          time.Duration(c.Int("metrics.exectimeout")) * time.Second,
+		ExecExitCode:         c.Bool("metrics.execexitcode"),
+		NagiosChecks:         c.StringSlice("metrics.nagios"),
+		StatsDAddress:        c.String("metrics.statsdaddress"),
+		StatsDTCPAddress:     c.String("metrics.statsdtcpaddress"),
+		StatsDPercentiles:    c.String("metrics.statsdpercentiles"),
+		PrometheusURLs:       c.StringSlice("metrics.prometheusurl"),
+		PrometheusAllow:      c.String("metrics.prometheusallow"),
+		PrometheusLabels:     c.String("metrics.prometheuslabels"),
+		Once:                 c.Bool("once"),
+		DryRun:               c.Bool("dry-run"),
+		DryRunFormat:         c.String("dry-run.format"),
+		PrometheusListen:     c.String("prometheus.listen"),
+		InfluxDBURL:          c.String("influxdb.url"),
+		InfluxDBDatabase:     c.String("influxdb.database"),
+		InfluxDBUsername:     c.String("influxdb.username"),
+		InfluxDBPassword:     c.String("influxdb.password"),
+		InfluxDBOrg:          c.String("influxdb.org"),
+		InfluxDBBucket:       c.String("influxdb.bucket"),
+		InfluxDBToken:        c.String("influxdb.token"),
+		InfluxDBBatchSize:    c.Int("influxdb.batchsize"),
+		InfluxDBGzip:         c.Bool("influxdb.gzip"),
+		GraphiteAddress:      c.String("graphite.address"),
+		GraphiteProtocol:     c.String("graphite.protocol"),
+		GraphiteTemplate:     c.String("graphite.template"),
+		EMFOutput:            c.String("emf.output"),
+		OTLPURL:              c.String("otlp.url"),
+		OTLPHeaders:          c.String("otlp.headers"),
+		OTLPResource:         c.String("otlp.resource"),
+		JSONOutput:           c.String("json.output"),
+		JSONMaxSize:          int64(c.Int("json.maxsize")) << 20,
+		JSONMaxAge:           time.Duration(c.Int("json.maxage")) * time.Hour,
+		JSONMaxBackups:       c.Int("json.maxbackups"),
+		DatadogAPIKey:        c.String("datadog.apikey"),
+		DatadogURL:           c.String("datadog.url"),
+		WebhookURL:           c.String("webhook.url"),
+		WebhookMethod:        c.String("webhook.method"),
+		WebhookTemplate:      c.String("webhook.template"),
+		WebhookHeaders:       c.String("webhook.headers"),
+		WebhookUsername:      c.String("webhook.username"),
+		WebhookPassword:      c.String("webhook.password"),
+		WebhookToken:         c.String("webhook.token"),
+		SinkRetries:          c.Int("sinks.retries"),
+		SinkBackoff:          time.Duration(c.Int("sinks.backoff")) * time.Second,
+		Client:               client,
 	}
 }
 
@@ -73,15 +142,175 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "metrics",
-			Usage:  "Comma separated list of metrics. Available: cpu, memory, swap, disk, docker-stats, docker-health",
+			Usage:  "Comma separated list of metrics. Available: cpu, memory, swap, disk, docker-stats, docker-health, docker-df, docker-swarm, cgroup, kubelet, http-check, tcp-check, dns-check, exec, nagios, statsd, prometheus",
 			Value:  "cpu,memory",
 			EnvVar: "CWMONITOR_METRICS",
 		},
+		cli.StringFlag{
+			Name:   "sinks",
+			Usage:  "Comma separated list of sinks the data is published to. Available: cloudwatch, prometheus, influxdb, graphite, emf, otlp, json, datadog, webhook",
+			Value:  "cloudwatch",
+			EnvVar: "CWMONITOR_SINKS",
+		},
 		cli.StringFlag{
 			Name:   "metrics.dockerlabel",
 			Usage:  "Container label to be used in place of container name for the CloudWatch dimension",
 			EnvVar: "CWMONITOR_METRICS_DOCKERLABEL",
 		},
+		cli.StringFlag{
+			Name:   "metrics.dockeraggregatelabel",
+			Usage:  "Container label used to aggregate docker metrics across replicas, e.g. com.docker.compose.service",
+			EnvVar: "CWMONITOR_METRICS_DOCKERAGGREGATELABEL",
+		},
+		cli.IntFlag{
+			Name:   "metrics.dockerconcurrency",
+			Usage:  "Maximum number of containers whose statistics are fetched in parallel",
+			Value:  8,
+			EnvVar: "CWMONITOR_METRICS_DOCKERCONCURRENCY",
+		},
+		cli.IntFlag{
+			Name:   "metrics.dockertimeout",
+			Usage:  "Timeout for fetching the statistics of a single container (seconds)",
+			Value:  5,
+			EnvVar: "CWMONITOR_METRICS_DOCKERTIMEOUT",
+		},
+		cli.BoolFlag{
+			Name:   "metrics.dockerstream",
+			Usage:  "Keep a streaming statistics connection open for every container instead of sampling on every interval",
+			EnvVar: "CWMONITOR_METRICS_DOCKERSTREAM",
+		},
+		cli.StringSliceFlag{
+			Name:   "metrics.dockerhost",
+			Usage:  "Docker daemon to monitor, e.g. unix:///run/podman/podman.sock or tcp://10.0.0.1:2376. Repeat to monitor several daemons. Defaults to the DOCKER_HOST environment variable",
+			EnvVar: "CWMONITOR_METRICS_DOCKERHOST",
+		},
+		cli.StringFlag{
+			Name:   "metrics.dockerapiversion",
+			Usage:  "Docker API version to use, negotiated with the daemon if not set",
+			EnvVar: "CWMONITOR_METRICS_DOCKERAPIVERSION",
+		},
+		cli.StringFlag{
+			Name:   "metrics.dockertlscacert",
+			Usage:  "CA certificate used to verify the docker daemons",
+			EnvVar: "CWMONITOR_METRICS_DOCKERTLSCACERT",
+		},
+		cli.StringFlag{
+			Name:   "metrics.dockertlscert",
+			Usage:  "Client certificate used to authenticate with the docker daemons",
+			EnvVar: "CWMONITOR_METRICS_DOCKERTLSCERT",
+		},
+		cli.StringFlag{
+			Name:   "metrics.dockertlskey",
+			Usage:  "Client key used to authenticate with the docker daemons",
+			EnvVar: "CWMONITOR_METRICS_DOCKERTLSKEY",
+		},
+		cli.BoolFlag{
+			Name:   "metrics.dockertlsverify",
+			Usage:  "Verify the certificate of the docker daemons",
+			EnvVar: "CWMONITOR_METRICS_DOCKERTLSVERIFY",
+		},
+		cli.StringFlag{
+			Name:   "metrics.cgrouproot",
+			Usage:  "Mount point of the cgroup hierarchy read by the cgroup metric",
+			Value:  "/sys/fs/cgroup",
+			EnvVar: "CWMONITOR_METRICS_CGROUPROOT",
+		},
+		cli.StringFlag{
+			Name:   "metrics.cgroupruntimeroot",
+			Usage:  "Root of the filesystem holding the metadata of the container runtimes used by the cgroup metric to name the containers",
+			Value:  "/",
+			EnvVar: "CWMONITOR_METRICS_CGROUPRUNTIMEROOT",
+		},
+		cli.StringFlag{
+			Name:   "metrics.kubeleturl",
+			Usage:  "URL of the kubelet read by the kubelet metric",
+			Value:  "https://localhost:10250",
+			EnvVar: "CWMONITOR_METRICS_KUBELETURL",
+		},
+		cli.StringFlag{
+			Name:   "metrics.kubelettokenfile",
+			Usage:  "File containing the bearer token used to authenticate with the kubelet",
+			Value:  "/var/run/secrets/kubernetes.io/serviceaccount/token",
+			EnvVar: "CWMONITOR_METRICS_KUBELETTOKENFILE",
+		},
+		cli.BoolFlag{
+			Name:   "metrics.kubeletinsecure",
+			Usage:  "Skip the verification of the kubelet serving certificate, usually self-signed",
+			EnvVar: "CWMONITOR_METRICS_KUBELETINSECURE",
+		},
+		cli.StringSliceFlag{
+			Name:   "metrics.httpcheck",
+			Usage:  "Target of the http-check metric as url[;method=GET][;status=200|204][;body=regex][;timeout=5s][;insecure=true]. Repeat to check several targets",
+			EnvVar: "CWMONITOR_METRICS_HTTPCHECK",
+		},
+		cli.StringSliceFlag{
+			Name:   "metrics.tcpcheck",
+			Usage:  "Target of the tcp-check metric as host:port. Repeat to check several targets",
+			EnvVar: "CWMONITOR_METRICS_TCPCHECK",
+		},
+		cli.StringSliceFlag{
+			Name:   "metrics.dnscheck",
+			Usage:  "Name resolved by the dns-check metric. Repeat to check several names",
+			EnvVar: "CWMONITOR_METRICS_DNSCHECK",
+		},
+		cli.StringFlag{
+			Name:   "metrics.dnsresolver",
+			Usage:  "Resolver used by the dns-check metric as host[:port]. Defaults to the resolvers configured for the host",
+			EnvVar: "CWMONITOR_METRICS_DNSRESOLVER",
+		},
+		cli.StringSliceFlag{
+			Name:   "metrics.exec",
+			Usage:  "Command run with sh by the exec metric printing data points on its standard output. Repeat to run several commands",
+			EnvVar: "CWMONITOR_METRICS_EXEC",
+		},
+		cli.IntFlag{
+			Name:   "metrics.exectimeout",
+			Usage:  "Timeout for the commands run by the exec and nagios metrics (seconds)",
+			Value:  10,
+			EnvVar: "CWMONITOR_METRICS_EXECTIMEOUT",
+		},
+		cli.BoolFlag{
+			Name:   "metrics.execexitcode",
+			Usage:  "Publish the exit code of the commands run by the exec metric",
+			EnvVar: "CWMONITOR_METRICS_EXECEXITCODE",
+		},
+		cli.StringSliceFlag{
+			Name:   "metrics.nagios",
+			Usage:  "Nagios compatible check plugin run with sh by the nagios metric, e.g. \"check_disk -w 10% -c 5% -p /\". Repeat to run several checks",
+			EnvVar: "CWMONITOR_METRICS_NAGIOS",
+		},
+		cli.StringFlag{
+			Name:   "metrics.statsdaddress",
+			Usage:  "UDP address the statsd metric listens on",
+			Value:  ":8125",
+			EnvVar: "CWMONITOR_METRICS_STATSDADDRESS",
+		},
+		cli.StringFlag{
+			Name:   "metrics.statsdtcpaddress",
+			Usage:  "TCP address the statsd metric listens on, disabled if not set",
+			EnvVar: "CWMONITOR_METRICS_STATSDTCPADDRESS",
+		},
+		cli.StringFlag{
+			Name:   "metrics.statsdpercentiles",
+			Usage:  "Comma separated list of percentiles reported for statsd timers",
+			Value:  "90",
+			EnvVar: "CWMONITOR_METRICS_STATSDPERCENTILES",
+		},
+		cli.StringSliceFlag{
+			Name:   "metrics.prometheusurl",
+			Usage:  "URL exposing metrics in the Prometheus text format scraped by the prometheus metric. Repeat to scrape several URLs",
+			EnvVar: "CWMONITOR_METRICS_PROMETHEUSURL",
+		},
+		cli.StringFlag{
+			Name:   "metrics.prometheusallow",
+			Usage:  "Comma separated list of patterns of the names of the prometheus metrics to publish, e.g. http_requests_*. All metrics if not set",
+			EnvVar: "CWMONITOR_METRICS_PROMETHEUSALLOW",
+		},
+		cli.StringFlag{
+			Name:   "metrics.prometheuslabels",
+			Usage:  "Comma separated list of prometheus labels published as dimensions as label[=Dimension]. All labels if not set",
+			EnvVar: "CWMONITOR_METRICS_PROMETHEUSLABELS",
+		},
 		cli.IntFlag{
 			Name:   "interval",
 			Usage:  "Time interval between data collection (seconds)",
@@ -94,10 +323,190 @@ func main() {
 			Value:  "CWMonitor",
 			EnvVar: "CWMONITOR_NAMESPACE",
 		},
+		cli.StringFlag{
+			Name:   "prometheus.listen",
+			Usage:  "Address the prometheus sink serves the gathered data on, in the Prometheus text format on /metrics, e.g. :9273",
+			EnvVar: "CWMONITOR_PROMETHEUS_LISTEN",
+		},
+		cli.StringFlag{
+			Name:   "influxdb.url",
+			Usage:  "URL of the InfluxDB the influxdb sink writes to, e.g. http://localhost:8086",
+			EnvVar: "CWMONITOR_INFLUXDB_URL",
+		},
+		cli.StringFlag{
+			Name:   "influxdb.database",
+			Usage:  "InfluxDB v1 database the influxdb sink writes to",
+			EnvVar: "CWMONITOR_INFLUXDB_DATABASE",
+		},
+		cli.StringFlag{
+			Name:   "influxdb.username",
+			Usage:  "Username of the InfluxDB v1 database",
+			EnvVar: "CWMONITOR_INFLUXDB_USERNAME",
+		},
+		cli.StringFlag{
+			Name:   "influxdb.password",
+			Usage:  "Password of the InfluxDB v1 database",
+			EnvVar: "CWMONITOR_INFLUXDB_PASSWORD",
+		},
+		cli.StringFlag{
+			Name:   "influxdb.org",
+			Usage:  "InfluxDB v2 organization of the bucket",
+			EnvVar: "CWMONITOR_INFLUXDB_ORG",
+		},
+		cli.StringFlag{
+			Name:   "influxdb.bucket",
+			Usage:  "InfluxDB v2 bucket the influxdb sink writes to. The v2 write API is used if set",
+			EnvVar: "CWMONITOR_INFLUXDB_BUCKET",
+		},
+		cli.StringFlag{
+			Name:   "influxdb.token",
+			Usage:  "InfluxDB v2 API token",
+			EnvVar: "CWMONITOR_INFLUXDB_TOKEN",
+		},
+		cli.IntFlag{
+			Name:   "influxdb.batchsize",
+			Usage:  "Maximum number of data points written to InfluxDB by a single request",
+			Value:  5000,
+			EnvVar: "CWMONITOR_INFLUXDB_BATCHSIZE",
+		},
+		cli.BoolFlag{
+			Name:   "influxdb.gzip",
+			Usage:  "Compress the requests to InfluxDB with gzip",
+			EnvVar: "CWMONITOR_INFLUXDB_GZIP",
+		},
+		cli.StringFlag{
+			Name:   "graphite.address",
+			Usage:  "Address of the Graphite plaintext listener the graphite sink writes to, e.g. localhost:2003",
+			EnvVar: "CWMONITOR_GRAPHITE_ADDRESS",
+		},
+		cli.StringFlag{
+			Name:   "graphite.protocol",
+			Usage:  "Protocol used to write to Graphite, tcp or udp",
+			Value:  "tcp",
+			EnvVar: "CWMONITOR_GRAPHITE_PROTOCOL",
+		},
+		cli.StringFlag{
+			Name:   "graphite.template",
+			Usage:  "Template of the Graphite path of the data points, e.g. {namespace}.{Host}.{Container}.{name}",
+			Value:  "{namespace}.{dimensions}.{name}",
+			EnvVar: "CWMONITOR_GRAPHITE_TEMPLATE",
+		},
+		cli.StringFlag{
+			Name:   "emf.output",
+			Usage:  "Output of the emf sink: stdout, a file path or the endpoint of a CloudWatch agent, e.g. tcp://127.0.0.1:25888 or udp://127.0.0.1:25888",
+			Value:  "stdout",
+			EnvVar: "CWMONITOR_EMF_OUTPUT",
+		},
+		cli.StringFlag{
+			Name:   "otlp.url",
+			Usage:  "OTLP/HTTP endpoint of the OpenTelemetry Collector the otlp sink exports to, e.g. http://localhost:4318",
+			EnvVar: "CWMONITOR_OTLP_URL",
+		},
+		cli.StringFlag{
+			Name:   "otlp.headers",
+			Usage:  "Comma separated list of headers sent with the OTLP requests, e.g. Authorization=Bearer token",
+			EnvVar: "CWMONITOR_OTLP_HEADERS",
+		},
+		cli.StringFlag{
+			Name:   "otlp.resource",
+			Usage:  "Comma separated list of resource attributes of the OTLP data, e.g. deployment.environment=production",
+			EnvVar: "CWMONITOR_OTLP_RESOURCE",
+		},
+		cli.StringFlag{
+			Name:   "json.output",
+			Usage:  "Output of the json sink: stdout or a file path",
+			Value:  "stdout",
+			EnvVar: "CWMONITOR_JSON_OUTPUT",
+		},
+		cli.IntFlag{
+			Name:   "json.maxsize",
+			Usage:  "Size at which the file of the json sink is rotated (megabytes). Disabled if zero",
+			Value:  100,
+			EnvVar: "CWMONITOR_JSON_MAXSIZE",
+		},
+		cli.IntFlag{
+			Name:   "json.maxage",
+			Usage:  "Age at which the file of the json sink is rotated (hours). Disabled if zero",
+			EnvVar: "CWMONITOR_JSON_MAXAGE",
+		},
+		cli.IntFlag{
+			Name:   "json.maxbackups",
+			Usage:  "Number of rotated files of the json sink to keep",
+			Value:  5,
+			EnvVar: "CWMONITOR_JSON_MAXBACKUPS",
+		},
+		cli.StringFlag{
+			Name:   "datadog.apikey",
+			Usage:  "API key of the Datadog account the datadog sink posts to",
+			EnvVar: "CWMONITOR_DATADOG_APIKEY",
+		},
+		cli.StringFlag{
+			Name:   "datadog.url",
+			Usage:  "URL of the Datadog site, e.g. https://api.datadoghq.eu",
+			Value:  "https://api.datadoghq.com",
+			EnvVar: "CWMONITOR_DATADOG_URL",
+		},
+		cli.StringFlag{
+			Name:   "webhook.url",
+			Usage:  "URL the webhook sink sends the data to",
+			EnvVar: "CWMONITOR_WEBHOOK_URL",
+		},
+		cli.StringFlag{
+			Name:   "webhook.method",
+			Usage:  "HTTP method of the webhook requests",
+			Value:  "POST",
+			EnvVar: "CWMONITOR_WEBHOOK_METHOD",
+		},
+		cli.StringFlag{
+			Name:   "webhook.template",
+			Usage:  "Go template of the body of the webhook requests, or @ followed by the path of a file containing it. Defaults to a JSON object with the namespace and the points",
+			EnvVar: "CWMONITOR_WEBHOOK_TEMPLATE",
+		},
+		cli.StringFlag{
+			Name:   "webhook.headers",
+			Usage:  "Comma separated list of headers sent with the webhook requests, e.g. X-Source=cwmonitor",
+			EnvVar: "CWMONITOR_WEBHOOK_HEADERS",
+		},
+		cli.StringFlag{
+			Name:   "webhook.username",
+			Usage:  "Username for the basic authentication of the webhook requests",
+			EnvVar: "CWMONITOR_WEBHOOK_USERNAME",
+		},
+		cli.StringFlag{
+			Name:   "webhook.password",
+			Usage:  "Password for the basic authentication of the webhook requests",
+			EnvVar: "CWMONITOR_WEBHOOK_PASSWORD",
+		},
+		cli.StringFlag{
+			Name:   "webhook.token",
+			Usage:  "Bearer token of the webhook requests",
+			EnvVar: "CWMONITOR_WEBHOOK_TOKEN",
+		},
+		cli.IntFlag{
+			Name:   "sinks.retries",
+			Usage:  "Number of times the failed requests of the datadog and webhook sinks are retried",
+			Value:  3,
+			EnvVar: "CWMONITOR_SINKS_RETRIES",
+		},
+		cli.IntFlag{
+			Name:   "sinks.backoff",
+			Usage:  "Wait before the first retry of a failed request, doubled at every following retry (seconds)",
+			Value:  1,
+			EnvVar: "CWMONITOR_SINKS_BACKOFF",
+		},
 		cli.BoolFlag{
 			Name:  "once",
 			Usage: "Run once (i.e. not on an interval)",
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print the requests that would be sent to CloudWatch instead of sending them. No AWS credentials are needed",
+		},
+		cli.StringFlag{
+			Name:  "dry-run.format",
+			Usage: "Format of the requests printed in dry run mode, table or json",
+			Value: "table",
+		},
 		cli.BoolFlag{
 			Name:  "debug",
 			Usage: "Enable debug logging",
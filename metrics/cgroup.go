@@ -0,0 +1,408 @@
+package metrics
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCgroupRoot = "/sys/fs/cgroup"
+	// memory limits above this value are reported by cgroup v1 when no limit is set
+	cgroupUnlimitedMemory = uint64(1) << 62
+)
+
+// containerIDPattern matches the cgroup directory names used for containers by docker, containerd, cri-o
+// and podman with either the cgroupfs or the systemd cgroup driver, e.g. <id>, docker-<id>.scope,
+// cri-containerd-<id>.scope, crio-<id>.scope or libpod-<id>.scope
+var containerIDPattern = regexp.MustCompile(`^(?:[a-z-]+-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// cgroupStats records the statistics read from the cgroup of a container
+type cgroupStats struct {
+	timestamp        time.Time
+	cpuUsage         uint64 // nanoseconds
+	periods          uint64
+	throttledPeriods uint64
+	throttledTime    uint64 // nanoseconds
+	memoryUsage      uint64
+	memoryLimit      uint64 // zero when no limit is set
+	ioReadBytes      uint64
+	ioWriteBytes     uint64
+	ioReadOps        uint64
+	ioWriteOps       uint64
+}
+
+// Cgroup collects container statistics directly from the cgroup v1 or v2 hierarchy mounted at Root
+// without the need of a docker daemon. This allows to monitor containers run by containerd, cri-o or podman.
+// Cgroups are mapped to containers by their ID and the containers are named after the metadata kept on disk
+// by their runtime under RuntimeRoot, / by default, or after the first 12 characters of their ID.
+type Cgroup struct {
+	Root        string
+	RuntimeRoot string
+
+	mu       sync.Mutex
+	previous map[string]cgroupStats
+	names    map[string]string
+}
+
+// NewCgroup creates a Cgroup metric reading the cgroup hierarchy mounted at the given root
+func NewCgroup(root string) *Cgroup {
+	return &Cgroup{Root: root, previous: map[string]cgroupStats{}}
+}
+
+// Name of the Cgroup metric
+func (c *Cgroup) Name() string {
+	return "cgroup"
+}
+
+func (c *Cgroup) root() string {
+	if c.Root != "" {
+		return c.Root
+	}
+	return defaultCgroupRoot
+}
+
+func (c *Cgroup) runtimeRoot() string {
+	if c.RuntimeRoot != "" {
+		return c.RuntimeRoot
+	}
+	return defaultRuntimeRoot
+}
+
+// containerNames returns the names of the given containers indexed by container ID. Names are only resolved
+// for the containers not found by the previous collection, since they are kept for the life of the container.
+func (c *Cgroup) containerNames(ids []string) map[string]string {
+	names := make(map[string]string, len(ids))
+	unknown := []string{}
+	for _, id := range ids {
+		if name, ok := c.names[id]; ok {
+			names[id] = name
+		} else {
+			unknown = append(unknown, id)
+		}
+	}
+	for id, name := range (containerNames{root: c.runtimeRoot()}).resolve(unknown) {
+		names[id] = name
+	}
+	return names
+}
+
+// isUnified returns true if the cgroup v2 unified hierarchy is mounted at the root
+func (c *Cgroup) isUnified() bool {
+	_, err := os.Stat(filepath.Join(c.root(), "cgroup.controllers"))
+	return err == nil
+}
+
+// findContainers walks the given hierarchy and returns the path, relative to the hierarchy,
+// of the cgroup of every container found indexed by container ID
+func findContainers(hierarchy string) (map[string]string, error) {
+	// v1 controllers are often mounted together and linked, e.g. cpuacct -> cpu,cpuacct
+	hierarchy, err := filepath.EvalSymlinks(hierarchy)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve cgroup hierarchy")
+	}
+
+	containers := map[string]string{}
+	if err := filepath.Walk(hierarchy, containerWalker(hierarchy, containers)); err != nil {
+		return nil, errors.Wrapf(err, "failed to walk cgroup hierarchy [%s]", hierarchy)
+	}
+	return containers, nil
+}
+
+// containerWalker returns the function walking the hierarchy and adding the cgroups of the containers found.
+// Cgroups removed during the walk, e.g. by a container stopping, are skipped.
+func containerWalker(hierarchy string, containers map[string]string) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || strings.Contains(info.Name(), "conmon") {
+			return nil
+		}
+
+		matches := containerIDPattern.FindStringSubmatch(info.Name())
+		if matches == nil {
+			return nil
+		}
+
+		relative, err := filepath.Rel(hierarchy, path)
+		if err != nil {
+			return err
+		}
+		containers[matches[1]] = relative
+		// nested cgroups belong to the container already found
+		return filepath.SkipDir
+	}
+}
+
+func readUint(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readKeyValues reads a flat keyed file, e.g. cpu.stat, where every line is in the form "key value"
+func readKeyValues(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readV1BlkioFile sums the Read and Write values across devices of a blkio file in the form "major:minor op value"
+func readV1BlkioFile(path string) (uint64, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var read, write uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[1] {
+		case "Read":
+			read += v
+		case "Write":
+			write += v
+		}
+	}
+	return read, write, scanner.Err()
+}
+
+// readV2IOStat sums the values across devices of an io.stat file in the form "major:minor key=value ..."
+func readV2IOStat(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if v, err := strconv.ParseUint(kv[1], 10, 64); err == nil {
+				values[kv[0]] += v
+			}
+		}
+	}
+	return values, scanner.Err()
+}
+
+// v1Hierarchy returns the path of the first existing hierarchy among the given controller mount points
+func (c *Cgroup) v1Hierarchy(names ...string) string {
+	for _, name := range names {
+		path := filepath.Join(c.root(), name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(c.root(), names[0])
+}
+
+// readV1 reads the statistics of the container cgroup found at the given relative path in the v1 hierarchies.
+// Missing files, e.g. for controllers not enabled, are ignored and the respective statistics are left to zero.
+func (c *Cgroup) readV1(path string) cgroupStats {
+	stats := cgroupStats{timestamp: time.Now()}
+
+	cpuacct := filepath.Join(c.v1Hierarchy("cpuacct", "cpu,cpuacct"), path)
+	stats.cpuUsage, _ = readUint(filepath.Join(cpuacct, "cpuacct.usage"))
+
+	cpu := filepath.Join(c.v1Hierarchy("cpu", "cpu,cpuacct"), path)
+	if cpuStat, err := readKeyValues(filepath.Join(cpu, "cpu.stat")); err == nil {
+		stats.periods = cpuStat["nr_periods"]
+		stats.throttledPeriods = cpuStat["nr_throttled"]
+		stats.throttledTime = cpuStat["throttled_time"]
+	}
+
+	memory := filepath.Join(c.v1Hierarchy("memory"), path)
+	stats.memoryUsage, _ = readUint(filepath.Join(memory, "memory.usage_in_bytes"))
+	if limit, err := readUint(filepath.Join(memory, "memory.limit_in_bytes")); err == nil && limit < cgroupUnlimitedMemory {
+		stats.memoryLimit = limit
+	}
+
+	blkio := filepath.Join(c.v1Hierarchy("blkio"), path)
+	stats.ioReadBytes, stats.ioWriteBytes, _ = readV1BlkioFile(filepath.Join(blkio, "blkio.throttle.io_service_bytes"))
+	stats.ioReadOps, stats.ioWriteOps, _ = readV1BlkioFile(filepath.Join(blkio, "blkio.throttle.io_serviced"))
+
+	return stats
+}
+
+// readV2 reads the statistics of the container cgroup found at the given relative path in the unified hierarchy.
+// Missing files, e.g. for controllers not enabled, are ignored and the respective statistics are left to zero.
+func (c *Cgroup) readV2(path string) cgroupStats {
+	stats := cgroupStats{timestamp: time.Now()}
+	dir := filepath.Join(c.root(), path)
+
+	if cpuStat, err := readKeyValues(filepath.Join(dir, "cpu.stat")); err == nil {
+		stats.cpuUsage = cpuStat["usage_usec"] * 1000
+		stats.periods = cpuStat["nr_periods"]
+		stats.throttledPeriods = cpuStat["nr_throttled"]
+		stats.throttledTime = cpuStat["throttled_usec"] * 1000
+	}
+
+	stats.memoryUsage, _ = readUint(filepath.Join(dir, "memory.current"))
+	// memory.max contains "max" when no limit is set and fails to parse
+	stats.memoryLimit, _ = readUint(filepath.Join(dir, "memory.max"))
+
+	if ioStat, err := readV2IOStat(filepath.Join(dir, "io.stat")); err == nil {
+		stats.ioReadBytes = ioStat["rbytes"]
+		stats.ioWriteBytes = ioStat["wbytes"]
+		stats.ioReadOps = ioStat["rios"]
+		stats.ioWriteOps = ioStat["wios"]
+	}
+
+	return stats
+}
+
+// cgroupStatsToData converts the statistics of a container cgroup into data points.
+// CPUUtilization requires a previous sample and is not returned for newly found containers.
+func cgroupStatsToData(stats cgroupStats, previous *cgroupStats, dimensions ...Dimension) Data {
+	data := Data{}
+
+	if previous != nil {
+		elapsed := stats.timestamp.Sub(previous.timestamp)
+		if elapsed > 0 && stats.cpuUsage >= previous.cpuUsage {
+			cpuUtilization := NewDataPoint(
+				"CPUUtilization",
+				float64(stats.cpuUsage-previous.cpuUsage)/float64(elapsed.Nanoseconds())*100,
+				UnitPercent,
+				dimensions...)
+			data = append(data, &cpuUtilization)
+		}
+	}
+
+	if stats.periods > 0 {
+		throttledPercentage := float64(stats.throttledPeriods) / float64(stats.periods) * 100
+		if previous != nil && stats.periods > previous.periods && stats.throttledPeriods >= previous.throttledPeriods {
+			throttledPercentage = float64(stats.throttledPeriods-previous.throttledPeriods) /
+				float64(stats.periods-previous.periods) * 100
+		}
+
+		throttledPeriodsPoint := NewCumulativeDataPoint("ThrottledPeriods", float64(stats.throttledPeriods), UnitCount, dimensions...)
+		throttledTimePoint := NewCumulativeDataPoint("ThrottledTime", time.Duration(stats.throttledTime).Seconds(), UnitSeconds, dimensions...)
+		throttledPercentagePoint := NewDataPoint("ThrottledPercentage", throttledPercentage, UnitPercent, dimensions...)
+		data = append(data, &throttledPeriodsPoint, &throttledTimePoint, &throttledPercentagePoint)
+	}
+
+	memoryUtilization := NewDataPoint("MemoryUtilization", float64(stats.memoryUsage), UnitBytes, dimensions...)
+	data = append(data, &memoryUtilization)
+	if stats.memoryLimit > 0 {
+		memoryLimit := NewDataPoint("MemoryLimit", float64(stats.memoryLimit), UnitBytes, dimensions...)
+		data = append(data, &memoryLimit)
+	}
+
+	ioReadBytes := NewCumulativeDataPoint("IOReadBytes", float64(stats.ioReadBytes), UnitBytes, dimensions...)
+	ioWriteBytes := NewCumulativeDataPoint("IOWriteBytes", float64(stats.ioWriteBytes), UnitBytes, dimensions...)
+	ioReadOps := NewCumulativeDataPoint("IOReadOperations", float64(stats.ioReadOps), UnitCount, dimensions...)
+	ioWriteOps := NewCumulativeDataPoint("IOWriteOperations", float64(stats.ioWriteOps), UnitCount, dimensions...)
+	return append(data, &ioReadBytes, &ioWriteBytes, &ioReadOps, &ioWriteOps)
+}
+
+// Gather statistics for the containers found in the cgroup hierarchy or error if the hierarchy cannot be read.
+// It will return the following data points for every container, with the container name as Container dimension
+// - CPUUtilization (percent) of a single CPU since the previous collection
+// - ThrottledPeriods (count), ThrottledTime (seconds) and ThrottledPercentage (percent) if a CPU quota is set
+// - MemoryUtilization (bytes) and MemoryLimit (bytes) if a memory limit is set
+// - IOReadBytes, IOWriteBytes (bytes), IOReadOperations and IOWriteOperations (count) since the container started
+func (c *Cgroup) Gather() (Data, error) {
+	log.Debug("gathering cgroup stats")
+
+	unified := c.isUnified()
+	hierarchy := c.root()
+	if !unified {
+		hierarchy = c.v1Hierarchy("cpuacct", "cpu,cpuacct")
+	}
+
+	containers, err := findContainers(hierarchy)
+	if err != nil {
+		return Data{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.previous == nil {
+		c.previous = map[string]cgroupStats{}
+	}
+
+	ids := make([]string, 0, len(containers))
+	for id := range containers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	names := c.containerNames(ids)
+	data := Data{}
+	current := make(map[string]cgroupStats, len(containers))
+	for _, id := range ids {
+		path := containers[id]
+		var stats cgroupStats
+		if unified {
+			stats = c.readV2(path)
+		} else {
+			stats = c.readV1(path)
+		}
+		current[id] = stats
+
+		containerDim, _ := NewDimension("Container", names[id])
+		var previous *cgroupStats
+		if p, ok := c.previous[id]; ok {
+			previous = &p
+		}
+		data = append(data, cgroupStatsToData(stats, previous, containerDim)...)
+	}
+	c.previous = current
+	c.names = names
+
+	return data, nil
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultRuntimeRoot = "/"
+	// dockerContainersDir holds the configuration of every docker container, including its name
+	dockerContainersDir = "var/lib/docker/containers"
+	// containerdTasksDir holds the OCI bundles of the running containerd tasks grouped by namespace
+	containerdTasksDir = "run/containerd/io.containerd.runtime.v2.task"
+	// containersStorageFile lists the containers of podman and cri-o with their names
+	containersStorageFile = "var/lib/containers/storage/overlay-containers/containers.json"
+)
+
+// annotations of the OCI runtime spec naming a container, set by nerdctl and by the CRI plugin of containerd,
+// which names the pod of the container with the sandbox annotation
+const (
+	nerdctlNameAnnotation      = "nerdctl/name"
+	criContainerNameAnnotation = "io.kubernetes.cri.container-name"
+	criSandboxNameAnnotation   = "io.kubernetes.cri.sandbox-name"
+)
+
+func readJSONFile(path string, v interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+// containerNames resolves the names of containers from the metadata the container runtimes keep on disk
+// under the root directory, e.g. the root of the host filesystem mounted in the cwmonitor container
+type containerNames struct {
+	root string
+}
+
+// docker returns the name of a docker container or an empty string if docker does not know the container
+func (n containerNames) docker(id string) string {
+	var config struct {
+		Name string
+	}
+	if err := readJSONFile(filepath.Join(n.root, dockerContainersDir, id, "config.v2.json"), &config); err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(config.Name, "/")
+}
+
+// containerd returns the name of a containerd container, as <pod>/<container> for the containers of
+// Kubernetes pods, or an empty string if the container is not running in containerd or is not named
+func (n containerNames) containerd(id string) string {
+	bundles, _ := filepath.Glob(filepath.Join(n.root, containerdTasksDir, "*", id, "config.json"))
+	for _, bundle := range bundles {
+		var spec struct {
+			Annotations map[string]string `json:"annotations"`
+		}
+		if err := readJSONFile(bundle, &spec); err != nil {
+			continue
+		}
+
+		if name := spec.Annotations[criContainerNameAnnotation]; name != "" {
+			if sandbox := spec.Annotations[criSandboxNameAnnotation]; sandbox != "" {
+				return sandbox + "/" + name
+			}
+			return name
+		}
+		if name := spec.Annotations[nerdctlNameAnnotation]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// containersStorage returns the names of the podman and cri-o containers indexed by container ID
+func (n containerNames) containersStorage() map[string]string {
+	var containers []struct {
+		ID    string   `json:"id"`
+		Names []string `json:"names"`
+	}
+	names := map[string]string{}
+	if err := readJSONFile(filepath.Join(n.root, containersStorageFile), &containers); err != nil {
+		return names
+	}
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names[c.ID] = c.Names[0]
+		}
+	}
+	return names
+}
+
+// resolve returns the names of the given containers indexed by container ID looking them up in the metadata
+// of docker, containerd, podman and cri-o in order. Containers not known to any runtime are named after
+// the first 12 characters of their ID.
+func (n containerNames) resolve(ids []string) map[string]string {
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names
+	}
+
+	storage := n.containersStorage()
+	for _, id := range ids {
+		name := n.docker(id)
+		if name == "" {
+			name = n.containerd(id)
+		}
+		if name == "" {
+			name = storage[id]
+		}
+		if name == "" {
+			name = id[:12]
+		}
+		names[id] = name
+	}
+	return names
+}
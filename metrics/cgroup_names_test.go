@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerNames_resolve(t *testing.T) {
+	containerdID := strings.Repeat("c", 64)
+	nerdctlID := strings.Repeat("d", 64)
+	unknownID := strings.Repeat("e", 64)
+
+	t.Run("names from the runtime metadata", func(t *testing.T) {
+		n := containerNames{root: "testdata/runtime"}
+		names := n.resolve([]string{cgroupContainerID1, cgroupContainerID2, containerdID, nerdctlID, unknownID})
+
+		assert.Equal(t, map[string]string{
+			cgroupContainerID1: "web",
+			cgroupContainerID2: "db",
+			containerdID:       "app-7d4b9c/app",
+			nerdctlID:          "cache",
+			unknownID:          unknownID[:12],
+		}, names)
+	})
+
+	t.Run("short IDs without runtime metadata", func(t *testing.T) {
+		n := containerNames{root: "testdata/missing"}
+		assert.Equal(t, map[string]string{cgroupContainerID1: cgroupContainerID1[:12]}, n.resolve([]string{cgroupContainerID1}))
+	})
+}
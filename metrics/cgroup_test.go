@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	cgroupContainerID1 = strings.Repeat("a", 64)
+	cgroupContainerID2 = strings.Repeat("b", 64)
+)
+
+func pointsByName(data Data) map[string]*Point {
+	points := map[string]*Point{}
+	for _, p := range data {
+		points[p.Name] = p
+	}
+	return points
+}
+
+func TestCgroup_Name(t *testing.T) {
+	c := NewCgroup("")
+	assert.Equal(t, "cgroup", c.Name())
+}
+
+func TestFindContainers(t *testing.T) {
+	t.Run("cgroup v2", func(t *testing.T) {
+		containers, err := findContainers("testdata/cgroup/v2")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			cgroupContainerID1: "system.slice/docker-" + cgroupContainerID1 + ".scope",
+			cgroupContainerID2: "machine.slice/libpod-" + cgroupContainerID2 + ".scope",
+		}, containers)
+	})
+
+	t.Run("cgroup v1", func(t *testing.T) {
+		containers, err := findContainers("testdata/cgroup/v1/cpu,cpuacct")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{cgroupContainerID1: "docker/" + cgroupContainerID1}, containers)
+	})
+
+	t.Run("missing hierarchy", func(t *testing.T) {
+		_, err := findContainers("testdata/cgroup/missing")
+		assert.Error(t, err)
+	})
+}
+
+func TestContainerWalker(t *testing.T) {
+	containers := map[string]string{}
+	walk := containerWalker("testdata/cgroup/v2", containers)
+	removed := &os.PathError{Op: "lstat", Path: "testdata/cgroup/v2/removed.scope", Err: syscall.ENOENT}
+
+	t.Run("skips removed cgroups", func(t *testing.T) {
+		assert.NoError(t, walk("testdata/cgroup/v2/removed.scope", nil, removed))
+	})
+
+	t.Run("skips removed directories", func(t *testing.T) {
+		info, err := os.Stat("testdata/cgroup/v2/system.slice")
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.SkipDir, walk("testdata/cgroup/v2/system.slice", info, removed))
+	})
+
+	t.Run("fails on other errors", func(t *testing.T) {
+		denied := &os.PathError{Op: "open", Path: "testdata/cgroup/v2/system.slice", Err: syscall.EACCES}
+		assert.Equal(t, denied, walk("testdata/cgroup/v2/system.slice", nil, denied))
+	})
+
+	assert.Empty(t, containers)
+}
+
+func TestCgroupStatsToData(t *testing.T) {
+	dim := Dimension{Name: "Container", Value: "a"}
+	now := time.Now()
+
+	t.Run("without previous sample", func(t *testing.T) {
+		stats := cgroupStats{timestamp: now, cpuUsage: 100, memoryUsage: 10}
+		points := pointsByName(cgroupStatsToData(stats, nil, dim))
+
+		assert.Len(t, points, 5)
+		assert.NotContains(t, points, "CPUUtilization")
+		assert.NotContains(t, points, "ThrottledPeriods")
+		assert.NotContains(t, points, "MemoryLimit")
+		assert.Equal(t, 10.0, points["MemoryUtilization"].Value)
+		assert.Equal(t, []Dimension{dim}, points["MemoryUtilization"].Dimensions)
+	})
+
+	t.Run("with previous sample", func(t *testing.T) {
+		previous := cgroupStats{timestamp: now, cpuUsage: 1e9, periods: 100, throttledPeriods: 10}
+		stats := cgroupStats{
+			timestamp:        now.Add(10 * time.Second),
+			cpuUsage:         6e9,
+			periods:          200,
+			throttledPeriods: 60,
+			throttledTime:    2e9,
+			memoryLimit:      100,
+		}
+		points := pointsByName(cgroupStatsToData(stats, &previous, dim))
+
+		assert.Len(t, points, 10)
+		assert.Equal(t, 50.0, points["CPUUtilization"].Value)
+		assert.Equal(t, string(UnitPercent), string(points["CPUUtilization"].Unit))
+		assert.Equal(t, 60.0, points["ThrottledPeriods"].Value)
+		assert.Equal(t, 2.0, points["ThrottledTime"].Value)
+		assert.Equal(t, string(UnitSeconds), string(points["ThrottledTime"].Unit))
+		assert.Equal(t, 50.0, points["ThrottledPercentage"].Value)
+		assert.Equal(t, 100.0, points["MemoryLimit"].Value)
+		assert.True(t, points["ThrottledPeriods"].Cumulative)
+		assert.True(t, points["IOReadBytes"].Cumulative)
+		assert.False(t, points["ThrottledPercentage"].Cumulative)
+	})
+}
+
+func TestCgroup_Gather(t *testing.T) {
+	t.Run("cgroup v2", func(t *testing.T) {
+		c := NewCgroup("testdata/cgroup/v2")
+		c.RuntimeRoot = "testdata/runtime"
+		data, err := c.Gather()
+		assert.NoError(t, err)
+
+		assert.Len(t, data, 14)
+		points := pointsByName(data[:9])
+		assert.Equal(t, []Dimension{{Name: "Container", Value: "web"}}, points["MemoryUtilization"].Dimensions)
+		assert.Equal(t, 104857600.0, points["MemoryUtilization"].Value)
+		assert.Equal(t, 268435456.0, points["MemoryLimit"].Value)
+		assert.Equal(t, 50.0, points["ThrottledPeriods"].Value)
+		assert.Equal(t, 1.5, points["ThrottledTime"].Value)
+		assert.Equal(t, 25.0, points["ThrottledPercentage"].Value)
+		assert.Equal(t, 2048.0, points["IOReadBytes"].Value)
+		assert.Equal(t, 2048.0, points["IOWriteBytes"].Value)
+		assert.Equal(t, 6.0, points["IOReadOperations"].Value)
+		assert.Equal(t, 8.0, points["IOWriteOperations"].Value)
+
+		points = pointsByName(data[9:])
+		assert.Equal(t, []Dimension{{Name: "Container", Value: "db"}}, points["MemoryUtilization"].Dimensions)
+		assert.Equal(t, 2048.0, points["MemoryUtilization"].Value)
+		assert.NotContains(t, points, "MemoryLimit")
+
+		data, err = c.Gather()
+		assert.NoError(t, err)
+		assert.Equal(t, "CPUUtilization", data[0].Name)
+	})
+
+	t.Run("cgroup v1", func(t *testing.T) {
+		c := NewCgroup("testdata/cgroup/v1")
+		c.RuntimeRoot = "testdata/missing"
+		data, err := c.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 8)
+
+		points := pointsByName(data)
+		assert.Equal(t, []Dimension{{Name: "Container", Value: cgroupContainerID1[:12]}}, points["MemoryUtilization"].Dimensions)
+		assert.Equal(t, 52428800.0, points["MemoryUtilization"].Value)
+		assert.NotContains(t, points, "MemoryLimit")
+		assert.Equal(t, 10.0, points["ThrottledPeriods"].Value)
+		assert.Equal(t, 0.25, points["ThrottledTime"].Value)
+		assert.Equal(t, 10.0, points["ThrottledPercentage"].Value)
+		assert.Equal(t, 4096.0, points["IOReadBytes"].Value)
+		assert.Equal(t, 8192.0, points["IOWriteBytes"].Value)
+		assert.Equal(t, 3.0, points["IOReadOperations"].Value)
+		assert.Equal(t, 5.0, points["IOWriteOperations"].Value)
+	})
+
+	t.Run("missing hierarchy", func(t *testing.T) {
+		c := NewCgroup("testdata/cgroup/missing")
+		data, err := c.Gather()
+		assert.Error(t, err)
+		assert.Len(t, data, 0)
+	})
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultDNSCheckTimeout = 5 * time.Second
+
+// DNSCheck checks the resolution of a list of names against Resolver, given as host or host:port,
+// or against the resolvers configured for the host if Resolver is empty
+type DNSCheck struct {
+	Names    []string
+	Resolver string
+	Timeout  time.Duration
+}
+
+// Name of the DNSCheck metric
+func (c DNSCheck) Name() string {
+	return "dns-check"
+}
+
+func (c DNSCheck) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultDNSCheckTimeout
+}
+
+func (c DNSCheck) resolver() *net.Resolver {
+	if c.Resolver == "" {
+		return net.DefaultResolver
+	}
+
+	address := c.Resolver
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		// every query is sent to the requested resolver in place of the ones configured for the host
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// check resolves the name and returns the data points describing the outcome
+func (c DNSCheck) check(resolver *net.Resolver, name string) Data {
+	targetDim, _ := NewDimension("Target", name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+
+	start := time.Now()
+	addresses, err := resolver.LookupHost(ctx, name)
+	if err != nil {
+		log.Warnf("dns check target [%s] failed: %s", name, err)
+		upPoint := NewDataPoint("Up", 0, UnitCount, targetDim)
+		return Data{&upPoint}
+	}
+	resolveTime := time.Since(start)
+
+	upPoint := NewDataPoint("Up", 1, UnitCount, targetDim)
+	resolveTimePoint := NewDataPoint("ResolveTime", float64(resolveTime)/float64(time.Millisecond), UnitMilliseconds, targetDim)
+	answersPoint := NewDataPoint("Answers", float64(len(addresses)), UnitCount, targetDim)
+	return Data{&upPoint, &resolveTimePoint, &answersPoint}
+}
+
+// Gather resolves all the names in parallel and returns the following data points for every name,
+// with the Target dimension
+// - Up (count) 1 if the name was resolved, 0 otherwise
+// - ResolveTime (milliseconds) if the name was resolved
+// - Answers (count) number of addresses the name resolved to
+func (c DNSCheck) Gather() (Data, error) {
+	log.Debug("gathering dns checks")
+
+	resolver := c.resolver()
+	results := make([]Data, len(c.Names))
+	var wg sync.WaitGroup
+	for i, name := range c.Names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = c.check(resolver, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	data := Data{}
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dnsStub is a minimal DNS server answering A queries for the configured names and
+// NXDOMAIN for any other name
+type dnsStub struct {
+	conn    net.PacketConn
+	records map[string][]net.IP
+}
+
+func newDNSStub(t *testing.T, records map[string][]net.IP) *dnsStub {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &dnsStub{conn: conn, records: records}
+	go s.serve()
+	return s
+}
+
+func (s *dnsStub) address() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *dnsStub) close() {
+	s.conn.Close()
+}
+
+func (s *dnsStub) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := s.answer(buf[:n]); response != nil {
+			s.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+// answer builds the response for the query with a single question
+func (s *dnsStub) answer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// the question name is a sequence of length prefixed labels terminated by a zero length label
+	offset, labels := 12, []string{}
+	for offset < len(query) && query[offset] != 0 {
+		length := int(query[offset])
+		if offset+1+length > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[offset+1:offset+1+length]))
+		offset += 1 + length
+	}
+	questionEnd := offset + 1 + 4
+	if questionEnd > len(query) {
+		return nil
+	}
+	name := strings.ToLower(strings.Join(labels, "."))
+	qtype := binary.BigEndian.Uint16(query[offset+1:])
+
+	ips, known := s.records[name]
+	rcode := uint16(0)
+	if !known {
+		rcode = 3
+	}
+	answers := []net.IP{}
+	if qtype == 1 {
+		answers = ips
+	}
+
+	response := make([]byte, 12, 512)
+	copy(response, query[:2])
+	binary.BigEndian.PutUint16(response[2:], 0x8180|rcode)
+	binary.BigEndian.PutUint16(response[4:], 1)
+	binary.BigEndian.PutUint16(response[6:], uint16(len(answers)))
+	response = append(response, query[12:questionEnd]...)
+	for _, ip := range answers {
+		// pointer to the question name, type A, class IN, TTL 60 and the 4 bytes address
+		response = append(response, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		response = append(response, ip.To4()...)
+	}
+	return response
+}
+
+func TestDNSCheck_Name(t *testing.T) {
+	c := DNSCheck{}
+	assert.Equal(t, "dns-check", c.Name())
+}
+
+func TestDNSCheck_Gather(t *testing.T) {
+	stub := newDNSStub(t, map[string][]net.IP{
+		"api.example.test": {net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+		"db.example.test":  {net.ParseIP("10.0.0.3")},
+	})
+	defer stub.close()
+
+	c := DNSCheck{Names: []string{"api.example.test.", "db.example.test.", "missing.example.test."}, Resolver: stub.address()}
+	data, err := c.Gather()
+
+	assert.NoError(t, err)
+	assert.Len(t, data, 7)
+
+	apiDim := Dimension{Name: "Target", Value: "api.example.test."}
+	assert.Equal(t, 1.0, findPoint(data, "Up", apiDim).Value)
+	assert.Equal(t, 2.0, findPoint(data, "Answers", apiDim).Value)
+	assert.True(t, findPoint(data, "ResolveTime", apiDim).Value >= 0)
+	assert.Equal(t, UnitMilliseconds, findPoint(data, "ResolveTime", apiDim).Unit)
+
+	dbDim := Dimension{Name: "Target", Value: "db.example.test."}
+	assert.Equal(t, 1.0, findPoint(data, "Up", dbDim).Value)
+	assert.Equal(t, 1.0, findPoint(data, "Answers", dbDim).Value)
+
+	missingDim := Dimension{Name: "Target", Value: "missing.example.test."}
+	assert.Equal(t, 0.0, findPoint(data, "Up", missingDim).Value)
+	assert.Nil(t, findPoint(data, "Answers", missingDim))
+}
+
+func TestDNSCheck_GatherUnreachableResolver(t *testing.T) {
+	// a resolver that never answers makes the lookup time out
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	c := DNSCheck{Names: []string{"api.example.test."}, Resolver: conn.LocalAddr().String(), Timeout: 50 * time.Millisecond}
+	data, err := c.Gather()
+
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Equal(t, 0.0, data[0].Value)
+}
+
+func TestDNSCheck_GatherDefaultPort(t *testing.T) {
+	c := DNSCheck{Names: []string{"api.example.test."}, Resolver: "127.0.0.1", Timeout: 50 * time.Millisecond}
+	data, err := c.Gather()
+
+	// nobody listens on the default DNS port of the loopback interface
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Equal(t, 0.0, data[0].Value)
+}
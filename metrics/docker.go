@@ -4,23 +4,78 @@ import (
 	"context"
 	"encoding/json"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
 	"github.com/pkg/errors"
 
 	log "github.com/sirupsen/logrus"
 )
 
 func computeCpu(stats types.StatsJSON) float64 {
-	//compute the cpu usage percentage
+	//compute the cpu usage percentage of a single CPU, as the cgroup and kubelet metrics
 	//via https://github.com/docker/docker/blob/e884a515e96201d4027a6c9c1b4fa884fc2d21a3/api/client/container/stats_helpers.go#L199-L212
-	newCpuUsage := stats.CPUStats.CPUUsage.TotalUsage
-	newSystemUsage := stats.CPUStats.SystemUsage
-	cpuDiff := float64(newCpuUsage)
-	systemDiff := float64(newSystemUsage)
-	return cpuDiff / systemDiff * float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	//when the previous sample is not available the deltas are computed since the start of the container
+	cpuDiff := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDiff := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if systemDiff <= 0 || cpuDiff < 0 {
+		return 0
+	}
+	return cpuDiff / systemDiff * float64(len(stats.CPUStats.CPUUsage.PercpuUsage)) * 100
+}
+
+// throttledPercentage returns the percentage of throttled CFS periods since the previous sample,
+// if available, or since the container started
+func throttledPercentage(throttling types.ThrottlingData, previous *types.ThrottlingData) float64 {
+	if previous != nil && throttling.Periods > previous.Periods && throttling.ThrottledPeriods >= previous.ThrottledPeriods {
+		return float64(throttling.ThrottledPeriods-previous.ThrottledPeriods) / float64(throttling.Periods-previous.Periods) * 100
+	}
+	return float64(throttling.ThrottledPeriods) / float64(throttling.Periods) * 100
+}
+
+// computeThrottling returns the data points for the CFS throttling of the container.
+// Throttling is only reported when a CPU quota is set for the container, i.e. when CFS periods are counted.
+// ThrottledPeriods and ThrottledTime are counted since the container started while ThrottledPercentage
+// is the percentage of throttled periods since the previous collection, if the container was already
+// running, or since the container started.
+// CPUQuotaCores is returned if the quota configured for the container is known.
+func computeThrottling(throttling types.ThrottlingData, previous *types.ThrottlingData, cpuQuota float64, dimensions ...Dimension) Data {
+	if throttling.Periods == 0 {
+		return Data{}
+	}
+
+	throttledPeriods := NewCumulativeDataPoint("ThrottledPeriods", float64(throttling.ThrottledPeriods), UnitCount, dimensions...)
+	throttledTime := NewCumulativeDataPoint("ThrottledTime", time.Duration(throttling.ThrottledTime).Seconds(), UnitSeconds, dimensions...)
+	throttledPercentagePoint := NewDataPoint("ThrottledPercentage", throttledPercentage(throttling, previous), UnitPercent, dimensions...)
+	data := Data{&throttledPeriods, &throttledTime, &throttledPercentagePoint}
+	if cpuQuota > 0 {
+		cpuQuotaCores := NewDataPoint("CPUQuotaCores", cpuQuota, UnitNone, dimensions...)
+		data = append(data, &cpuQuotaCores)
+	}
+	return data
+}
 
+// computePids returns the data points for the number of processes and threads running in the container.
+// PidsStats are only reported on Linux hence no data points are returned when they are not available.
+// PidsLimit and PidsUtilization are only returned when a limit is set for the container.
+func computePids(stats types.StatsJSON, dimensions ...Dimension) Data {
+	if stats.PidsStats.Current == 0 {
+		return Data{}
+	}
+
+	pidsCurrent := NewDataPoint("PidsCurrent", float64(stats.PidsStats.Current), UnitCount, dimensions...)
+	data := Data{&pidsCurrent}
+	if stats.PidsStats.Limit > 0 {
+		pidsLimit := NewDataPoint("PidsLimit", float64(stats.PidsStats.Limit), UnitCount, dimensions...)
+		pidsUtilization := NewDataPoint(
+			"PidsUtilization",
+			float64(stats.PidsStats.Current)/float64(stats.PidsStats.Limit)*100,
+			UnitPercent,
+			dimensions...)
+		data = append(data, &pidsLimit, &pidsUtilization)
+	}
+	return data
 }
 
 // GetDimensionsFromContainer is a utility function to construct dimensions from a container
@@ -41,35 +96,86 @@ func GetDimensionsFromContainer(container types.Container, label string) []Dimen
 }
 
 type dockerMetric struct {
-	client client.ContainerAPIClient
+	client dockerClient
+	// apiVersion is the API version of the daemon, or the pinned version, used by the raw requests
+	apiVersion string
+
+	Endpoint DockerEndpoint
 }
 
 func (d *dockerMetric) initClient() error {
 	if d.client == nil {
-		cli, err := client.NewEnvClient()
+		cli, err := d.Endpoint.newClient()
 		if err != nil {
-			return errors.Wrap(err, "failed to create docker client from environment variables")
+			return err
 		}
 
+		d.apiVersion = cli.ClientVersion()
+		if d.Endpoint.apiVersion() == "" {
+			d.apiVersion = negotiateAPIVersion(cli)
+		}
 		d.client = cli
 	}
 	return nil
 }
 
-// DockerStat collects docker statistics from the running containers
+const (
+	defaultDockerConcurrency = 8
+	defaultDockerTimeout     = 5 * time.Second
+	defaultDockerDeadline    = 30 * time.Second
+)
+
+// DockerStat collects docker statistics from the running containers.
+// Statistics are fetched in parallel by at most Concurrency workers, each request is bounded by
+// Timeout and the whole collection is bounded by Deadline.
 type DockerStat struct {
 	dockerMetric
 
-	Label string
+	Label          string
+	AggregateLabel string
+	Concurrency    int
+	Timeout        time.Duration
+	Deadline       time.Duration
+
+	// throttling is the CFS throttling of every container at the previous collection
+	throttling map[string]types.ThrottlingData
+}
+
+type statsResult struct {
+	stats    types.StatsJSON
+	cpuQuota float64
+	err      error
+	timedOut bool
 }
 
 // Name of the DockerStat metric
-func (d DockerStat) Name() string {
+func (d *DockerStat) Name() string {
 	return "docker-stat"
 }
 
-func (d DockerStat) getStats(containerID string) (types.StatsJSON, error) {
-	response, err := d.client.ContainerStats(context.Background(), containerID, false)
+func (d *DockerStat) concurrency() int {
+	if d.Concurrency > 0 {
+		return d.Concurrency
+	}
+	return defaultDockerConcurrency
+}
+
+func (d *DockerStat) timeout() time.Duration {
+	if d.Timeout > 0 {
+		return d.Timeout
+	}
+	return defaultDockerTimeout
+}
+
+func (d *DockerStat) deadline() time.Duration {
+	if d.Deadline > 0 {
+		return d.Deadline
+	}
+	return defaultDockerDeadline
+}
+
+func (d *DockerStat) getStats(ctx context.Context, containerID string) (types.StatsJSON, error) {
+	response, err := d.client.ContainerStats(ctx, containerID, false)
 	if err != nil {
 		return types.StatsJSON{}, errors.Wrapf(err, "failed to fetch statistics for container ID [%s]", containerID)
 	}
@@ -85,29 +191,168 @@ func (d DockerStat) getStats(containerID string) (types.StatsJSON, error) {
 	return *v, nil
 }
 
+// getCPUQuota returns the CPU quota configured for the container expressed as number of cores
+func (d *DockerStat) getCPUQuota(ctx context.Context, containerID string) (float64, error) {
+	c, err := d.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to inspect container ID [%s]", containerID)
+	}
+	if c.ContainerJSONBase == nil || c.HostConfig == nil {
+		return 0, nil
+	}
+
+	resources := c.HostConfig.Resources
+	if resources.NanoCPUs > 0 {
+		return float64(resources.NanoCPUs) / 1e9, nil
+	}
+	if resources.CPUQuota > 0 && resources.CPUPeriod > 0 {
+		return float64(resources.CPUQuota) / float64(resources.CPUPeriod), nil
+	}
+	return 0, nil
+}
+
+func (d *DockerStat) getStatsWithTimeout(ctx context.Context, containerID string) statsResult {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout())
+	defer cancel()
+
+	stats, err := d.getStats(ctx, containerID)
+	if err != nil {
+		return statsResult{err: err, timedOut: ctx.Err() == context.DeadlineExceeded}
+	}
+
+	// the quota is only looked up for containers being throttled to avoid inspecting every container
+	var cpuQuota float64
+	if stats.CPUStats.ThrottlingData.Periods > 0 {
+		if cpuQuota, err = d.getCPUQuota(ctx, containerID); err != nil {
+			log.Warnf("failed to fetch the CPU quota for container ID [%s]: %s", containerID, err)
+		}
+	}
+	return statsResult{stats: stats, cpuQuota: cpuQuota}
+}
+
+// collectStats fetches the statistics for the given containers using a bounded pool of workers.
+// It returns the results in the same order of the containers and the number of containers
+// whose statistics could not be fetched before their timeout or the deadline of the context.
+func (d *DockerStat) collectStats(ctx context.Context, containers []types.Container) ([]statsResult, int) {
+	results := make([]statsResult, len(containers))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < d.concurrency(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = d.getStatsWithTimeout(ctx, containers[i].ID)
+			}
+		}()
+	}
+
+	for i := range containers {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			results[i] = statsResult{err: errors.Wrap(ctx.Err(), "deadline reached before fetching statistics"), timedOut: true}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	timeouts := 0
+	for _, r := range results {
+		if r.timedOut {
+			timeouts++
+		}
+	}
+	return results, timeouts
+}
+
 // Gather statistics from the running containers. It will return data for the CPUUtilization (percent)
 // and MemoryUtilization (bytes) for every container or error if the list of containers cannot be fetched.
+// On Linux the PidsCurrent (count) and, if the container has a pids limit, the PidsLimit (count) and
+// PidsUtilization (percent) are returned as well. If the container has a CPU quota the ThrottledPeriods (count),
+// ThrottledTime (seconds), ThrottledPercentage (percent) and CPUQuotaCores (none) are also returned.
 // If gathering statistics for a container fails the respective data points will not be returned
-// and a warning will be logged
-func (d DockerStat) Gather() (Data, error) {
+// and a warning will be logged.
+// If an AggregateLabel is set the containers are grouped by the value of that label and the sum,
+// average and maximum of CPUUtilization, MemoryUtilization, PidsCurrent, PidsUtilization and
+// ThrottledPercentage are returned for every group instead. The counters since the containers started,
+// ThrottledPeriods and ThrottledTime, are not aggregated since their sum would drop whenever a container
+// of the group is replaced, and neither are the limits, PidsLimit and CPUQuotaCores, which are configured
+// per container.
+// The number of containers whose statistics could not be fetched in time is returned as the
+// ContainerStatsTimeouts (count) data point. Listing the containers and fetching their statistics
+// is bounded by Deadline.
+func (d *DockerStat) Gather() (Data, error) {
 	log.Debug("gathering docker stats")
 
 	if err := d.initClient(); err != nil {
 		return Data{}, err
 	}
 
-	containers, err := d.client.ContainerList(context.Background(), types.ContainerListOptions{All: false})
+	ctx, cancel := context.WithTimeout(context.Background(), d.deadline())
+	defer cancel()
+
+	containers, err := d.client.ContainerList(ctx, types.ContainerListOptions{All: false})
 	if err != nil {
 		return Data{}, errors.Wrap(err, "failed to list containers")
 	}
 
+	results, timeouts := d.collectStats(ctx, containers)
+	data := d.statsToData(containers, results)
+
+	if timeouts > 0 {
+		log.Warnf("timed out fetching statistics for %d of %d containers", timeouts, len(containers))
+	}
+	timeoutsDataPoint := NewDataPoint("ContainerStatsTimeouts", float64(timeouts), UnitCount)
+	data = append(data, &timeoutsDataPoint)
+
+	data.AddDimensions(d.Endpoint.hostDimensions()...)
+	return data, nil
+}
+
+// statsToData converts the statistics fetched for the given containers into data points
+func (d *DockerStat) statsToData(containers []types.Container, results []statsResult) Data {
+	// statistics are not gathered concurrently hence the previous throttling is not guarded
+	if d.throttling == nil {
+		d.throttling = map[string]types.ThrottlingData{}
+	}
+	throttling := make(map[string]types.ThrottlingData, len(containers))
+
 	data := Data{}
-	for _, container := range containers {
+	groups := containerGroups{}
+	for i, container := range containers {
 		dimensions := GetDimensionsFromContainer(container, d.Label)
 
-		stats, err := d.getStats(container.ID)
+		var previous *types.ThrottlingData
+		if p, ok := d.throttling[container.ID]; ok {
+			previous = &p
+		}
+
+		stats, err := results[i].stats, results[i].err
 		if err != nil {
 			log.Warnf("failed to fetch statistics for container ID [%s]: %s", container.ID, err)
+			// the previous throttling is kept to compute the throttling at the next collection
+			if previous != nil {
+				throttling[container.ID] = *previous
+			}
+			continue
+		}
+		throttling[container.ID] = stats.CPUStats.ThrottlingData
+
+		if d.AggregateLabel != "" {
+			group := GetGroupDimensionFromContainer(container, d.AggregateLabel)
+			groups.add(group, "CPUUtilization", computeCpu(stats))
+			groups.add(group, "MemoryUtilization", float64(stats.MemoryStats.Usage))
+			if stats.PidsStats.Current > 0 {
+				groups.add(group, "PidsCurrent", float64(stats.PidsStats.Current))
+				if stats.PidsStats.Limit > 0 {
+					groups.add(group, "PidsUtilization", float64(stats.PidsStats.Current)/float64(stats.PidsStats.Limit)*100)
+				}
+			}
+			if stats.CPUStats.ThrottlingData.Periods > 0 {
+				groups.add(group, "ThrottledPercentage", throttledPercentage(stats.CPUStats.ThrottlingData, previous))
+			}
 			continue
 		}
 
@@ -116,27 +361,42 @@ func (d DockerStat) Gather() (Data, error) {
 
 		memoryUtilization := NewDataPoint("MemoryUtilization", float64(stats.MemoryStats.Usage), UnitBytes, dimensions...)
 		data = append(data, &memoryUtilization)
+
+		data = append(data, computeThrottling(stats.CPUStats.ThrottlingData, previous, results[i].cpuQuota, dimensions...)...)
+		data = append(data, computePids(stats, dimensions...)...)
+	}
+	d.throttling = throttling
+
+	for _, group := range groups.sortedGroups() {
+		data = append(data, aggregateValues("CPUUtilization", groups[group]["CPUUtilization"], UnitPercent, group)...)
+		data = append(data, aggregateValues("MemoryUtilization", groups[group]["MemoryUtilization"], UnitBytes, group)...)
+		data = append(data, aggregateValues("PidsCurrent", groups[group]["PidsCurrent"], UnitCount, group)...)
+		data = append(data, aggregateValues("PidsUtilization", groups[group]["PidsUtilization"], UnitPercent, group)...)
+		data = append(data, aggregateValues("ThrottledPercentage", groups[group]["ThrottledPercentage"], UnitPercent, group)...)
 	}
 
-	return data, nil
+	return data
 }
 
 // DockerHealth collects docker health from running containers
 type DockerHealth struct {
 	dockerMetric
 
-	Label string
+	Label          string
+	AggregateLabel string
 }
 
 // Name of the DockerHealth metric
-func (d DockerHealth) Name() string {
+func (d *DockerHealth) Name() string {
 	return "docker-health"
 }
 
 // Gather the health status from running containers or error if unable to get a list of running containers.
 // If a container does not have a HealthCheck defined it will be reported as unhealthy. If inspection for
 // a running container fails the respective data will not be reported and a warning will be logged.
-func (d DockerHealth) Gather() (Data, error) {
+// If an AggregateLabel is set the containers are grouped by the value of that label and the number of
+// HealthyReplicas and TotalReplicas are returned for every group instead.
+func (d *DockerHealth) Gather() (Data, error) {
 	log.Debug("gathering docker health")
 
 	if err := d.initClient(); err != nil {
@@ -149,6 +409,7 @@ func (d DockerHealth) Gather() (Data, error) {
 	}
 
 	data := Data{}
+	groups := containerGroups{}
 	for _, container := range containers {
 		dimensions := GetDimensionsFromContainer(container, d.Label)
 
@@ -162,9 +423,26 @@ func (d DockerHealth) Gather() (Data, error) {
 		if c.State != nil && c.State.Health != nil && strings.ToLower(c.State.Health.Status) == "healthy" {
 			value = 1.0
 		}
+
+		if d.AggregateLabel != "" {
+			groups.add(GetGroupDimensionFromContainer(container, d.AggregateLabel), "Health", value)
+			continue
+		}
+
 		healthDataPoint := NewDataPoint("Health", value, UnitCount, dimensions...)
 		data = append(data, &healthDataPoint)
 	}
 
+	for _, group := range groups.sortedGroups() {
+		healthy := 0.0
+		for _, v := range groups[group]["Health"] {
+			healthy += v
+		}
+		healthyReplicas := NewDataPoint("HealthyReplicas", healthy, UnitCount, group)
+		totalReplicas := NewDataPoint("TotalReplicas", float64(len(groups[group]["Health"])), UnitCount, group)
+		data = append(data, &healthyReplicas, &totalReplicas)
+	}
+
+	data.AddDimensions(d.Endpoint.hostDimensions()...)
 	return data, nil
 }
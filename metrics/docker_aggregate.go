@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// GetGroupDimensionFromContainer is a utility function to construct the dimension used to aggregate
+// containers across replicas. It creates a Dimension with name Service and value given by the value
+// of the requested label if present for the container or by the name of the container otherwise
+func GetGroupDimensionFromContainer(container types.Container, label string) Dimension {
+	var groupDim Dimension
+	if value, ok := container.Labels[label]; ok {
+		groupDim, _ = NewDimension("Service", value)
+	} else if len(container.Names) > 0 {
+		groupDim, _ = NewDimension("Service", strings.Trim(container.Names[0], "/"))
+	} else {
+		groupDim, _ = NewDimension("Service", container.ID)
+	}
+	return groupDim
+}
+
+// containerGroups collects the values reported by the containers belonging to the same group
+type containerGroups map[Dimension]map[string][]float64
+
+func (g containerGroups) add(group Dimension, name string, value float64) {
+	if _, ok := g[group]; !ok {
+		g[group] = map[string][]float64{}
+	}
+	g[group][name] = append(g[group][name], value)
+}
+
+// sortedGroups returns the group dimensions sorted by value
+func (g containerGroups) sortedGroups() []Dimension {
+	groups := make([]Dimension, 0, len(g))
+	for group := range g {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Value < groups[j].Value })
+	return groups
+}
+
+// aggregateValues returns the data points for the sum, average and maximum of the given values
+func aggregateValues(name string, values []float64, unit Unit, dimensions ...Dimension) Data {
+	if len(values) == 0 {
+		return Data{}
+	}
+
+	sum, max := 0.0, values[0]
+	for _, v := range values {
+		sum += v
+		if v > max {
+			max = v
+		}
+	}
+
+	sumPoint := NewDataPoint(name+"Sum", sum, unit, dimensions...)
+	averagePoint := NewDataPoint(name+"Average", sum/float64(len(values)), unit, dimensions...)
+	maxPoint := NewDataPoint(name+"Maximum", max, unit, dimensions...)
+	return Data{&sumPoint, &averagePoint, &maxPoint}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGroupDimensionFromContainer(t *testing.T) {
+	t.Run("uses label if present", func(t *testing.T) {
+		labels := map[string]string{"service": "web"}
+		c := types.Container{ID: "id", Names: []string{"name"}, Labels: labels}
+		dim := GetGroupDimensionFromContainer(c, "service")
+		assert.Equal(t, Dimension{Name: "Service", Value: "web"}, dim)
+	})
+
+	t.Run("uses name if label is not set", func(t *testing.T) {
+		c := types.Container{ID: "id", Names: []string{"/name"}}
+		dim := GetGroupDimensionFromContainer(c, "service")
+		assert.Equal(t, Dimension{Name: "Service", Value: "name"}, dim)
+	})
+
+	t.Run("uses id if name not available", func(t *testing.T) {
+		c := types.Container{ID: "id"}
+		dim := GetGroupDimensionFromContainer(c, "service")
+		assert.Equal(t, Dimension{Name: "Service", Value: "id"}, dim)
+	})
+}
+
+func TestAggregateValues(t *testing.T) {
+	t.Run("empty values", func(t *testing.T) {
+		data := aggregateValues("CPUUtilization", []float64{}, UnitPercent)
+		assert.Len(t, data, 0)
+	})
+
+	t.Run("sum, average and maximum", func(t *testing.T) {
+		dim := Dimension{Name: "Service", Value: "web"}
+		data := aggregateValues("CPUUtilization", []float64{1, 4, 7}, UnitPercent, dim)
+		assert.Len(t, data, 3)
+
+		assert.Equal(t, "CPUUtilizationSum", data[0].Name)
+		assert.Equal(t, 12.0, data[0].Value)
+		assert.Equal(t, "CPUUtilizationAverage", data[1].Name)
+		assert.Equal(t, 4.0, data[1].Value)
+		assert.Equal(t, "CPUUtilizationMaximum", data[2].Name)
+		assert.Equal(t, 7.0, data[2].Value)
+
+		for _, p := range data {
+			assert.Equal(t, string(UnitPercent), string(p.Unit))
+			assert.Equal(t, []Dimension{dim}, p.Dimensions)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dockerBuildCacheAPIVersion is the first version of the API reporting the build cache in the disk usage
+const dockerBuildCacheAPIVersion = "1.39"
+
+// dockerDiskUsage is the disk usage response including the build cache, not decoded by the docker client in use
+type dockerDiskUsage struct {
+	types.DiskUsage
+	BuildCache []struct {
+		Size  int64
+		InUse bool
+	}
+}
+
+// DockerDiskUsage collects the disk space used by the docker daemon for images, containers, volumes and build cache
+type DockerDiskUsage struct {
+	dockerMetric
+	Timeout time.Duration
+}
+
+// Name of the DockerDiskUsage metric
+func (d *DockerDiskUsage) Name() string {
+	return "docker-df"
+}
+
+func (d *DockerDiskUsage) timeout() time.Duration {
+	if d.Timeout > 0 {
+		return d.Timeout
+	}
+	return defaultDockerDeadline
+}
+
+// diskUsage fetches the disk usage of the daemon with a single request, returning whether the build cache is
+// included. The disk usage is requested directly, with the API version reporting the build cache, if the daemon
+// supports it since the docker client in use predates the build cache.
+func (d *DockerDiskUsage) diskUsage() (dockerDiskUsage, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout())
+	defer cancel()
+
+	if versions.LessThan(d.apiVersion, dockerBuildCacheAPIVersion) {
+		usage, err := d.client.DiskUsage(ctx)
+		return dockerDiskUsage{DiskUsage: usage}, false, err
+	}
+
+	var usage dockerDiskUsage
+	err := d.client.getJSON(ctx, dockerBuildCacheAPIVersion, "/system/df", &usage)
+	return usage, true, err
+}
+
+// Gather the disk usage of the docker daemon or error if the disk usage cannot be fetched.
+// It will return the following data points
+// - ImagesCount (count)
+// - ImagesSize (bytes) size of all the image layers
+// - ImagesReclaimableSize (bytes) size of the image layers not used by any container
+// - ContainersCount (count)
+// - ContainersSize (bytes) size of the writable layers of the containers
+// - ContainersReclaimableSize (bytes) size of the writable layers of the containers not running
+// - VolumesCount (count)
+// - VolumesSize (bytes) size of the local volumes
+// - VolumesReclaimableSize (bytes) size of the local volumes not referenced by any container
+// - BuildCacheSize (bytes) size of the build cache
+// - BuildCacheReclaimableSize (bytes) size of the build cache not in use
+// The build cache data points are only reported by daemons supporting API version 1.39 or later.
+// The disk usage request is abandoned after Timeout, 30 seconds by default.
+func (d *DockerDiskUsage) Gather() (Data, error) {
+	log.Debug("gathering docker disk usage")
+
+	if err := d.initClient(); err != nil {
+		return Data{}, err
+	}
+
+	usage, buildCache, err := d.diskUsage()
+	if err != nil {
+		return Data{}, errors.Wrap(err, "failed to fetch docker disk usage")
+	}
+
+	// the size of the images used by containers is computed as in the docker CLI
+	// via https://github.com/docker/cli/blob/v18.06.0-ce/cli/command/formatter/disk_usage.go#L222-L236
+	var imagesUsed int64
+	for _, image := range usage.Images {
+		if image.Containers > 0 && image.VirtualSize != -1 && image.SharedSize != -1 {
+			imagesUsed += image.VirtualSize - image.SharedSize
+		}
+	}
+	imagesReclaimable := usage.LayersSize - imagesUsed
+	if imagesReclaimable < 0 {
+		imagesReclaimable = 0
+	}
+
+	var containersSize, containersReclaimable int64
+	for _, container := range usage.Containers {
+		containersSize += container.SizeRw
+		if container.State != "running" {
+			containersReclaimable += container.SizeRw
+		}
+	}
+
+	var volumesSize, volumesReclaimable int64
+	for _, volume := range usage.Volumes {
+		if volume.UsageData == nil || volume.UsageData.Size < 0 {
+			continue
+		}
+		volumesSize += volume.UsageData.Size
+		if volume.UsageData.RefCount == 0 {
+			volumesReclaimable += volume.UsageData.Size
+		}
+	}
+
+	imagesCount := NewDataPoint("ImagesCount", float64(len(usage.Images)), UnitCount)
+	imagesSize := NewDataPoint("ImagesSize", float64(usage.LayersSize), UnitBytes)
+	imagesReclaimableSize := NewDataPoint("ImagesReclaimableSize", float64(imagesReclaimable), UnitBytes)
+	containersCount := NewDataPoint("ContainersCount", float64(len(usage.Containers)), UnitCount)
+	containersSizePoint := NewDataPoint("ContainersSize", float64(containersSize), UnitBytes)
+	containersReclaimableSize := NewDataPoint("ContainersReclaimableSize", float64(containersReclaimable), UnitBytes)
+	volumesCount := NewDataPoint("VolumesCount", float64(len(usage.Volumes)), UnitCount)
+	volumesSizePoint := NewDataPoint("VolumesSize", float64(volumesSize), UnitBytes)
+	volumesReclaimableSize := NewDataPoint("VolumesReclaimableSize", float64(volumesReclaimable), UnitBytes)
+	data := Data([]*Point{
+		&imagesCount, &imagesSize, &imagesReclaimableSize,
+		&containersCount, &containersSizePoint, &containersReclaimableSize,
+		&volumesCount, &volumesSizePoint, &volumesReclaimableSize,
+	})
+	if buildCache {
+		var buildCacheSize, buildCacheReclaimable int64
+		for _, cache := range usage.BuildCache {
+			buildCacheSize += cache.Size
+			if !cache.InUse {
+				buildCacheReclaimable += cache.Size
+			}
+		}
+		buildCacheSizePoint := NewDataPoint("BuildCacheSize", float64(buildCacheSize), UnitBytes)
+		buildCacheReclaimableSize := NewDataPoint("BuildCacheReclaimableSize", float64(buildCacheReclaimable), UnitBytes)
+		data = append(data, &buildCacheSizePoint, &buildCacheReclaimableSize)
+	}
+	data.AddDimensions(d.Endpoint.hostDimensions()...)
+	return data, nil
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerDiskUsage_Name(t *testing.T) {
+	d := DockerDiskUsage{}
+	assert.Equal(t, "docker-df", d.Name())
+}
+
+func TestDockerDiskUsage_Gather(t *testing.T) {
+	t.Run("disk usage", func(t *testing.T) {
+		usage := types.DiskUsage{
+			LayersSize: 1000,
+			Images: []*types.ImageSummary{
+				{Containers: 1, VirtualSize: 400, SharedSize: 100},
+				{Containers: 0, VirtualSize: 500, SharedSize: 100},
+				{Containers: 2, VirtualSize: 200, SharedSize: -1},
+			},
+			Containers: []*types.Container{
+				{SizeRw: 10, State: "running"},
+				{SizeRw: 20, State: "exited"},
+			},
+			Volumes: []*types.Volume{
+				{UsageData: &types.VolumeUsageData{RefCount: 1, Size: 100}},
+				{UsageData: &types.VolumeUsageData{RefCount: 0, Size: 50}},
+				{UsageData: &types.VolumeUsageData{RefCount: 0, Size: -1}},
+				{},
+			},
+		}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("DiskUsage").Return(usage, nil)
+
+		d := DockerDiskUsage{dockerMetric: dockerMetric{client: mockClient, apiVersion: "1.25"}}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 9)
+
+		expected := []struct {
+			name  string
+			value float64
+			unit  Unit
+		}{
+			{"ImagesCount", 3, UnitCount},
+			{"ImagesSize", 1000, UnitBytes},
+			{"ImagesReclaimableSize", 700, UnitBytes},
+			{"ContainersCount", 2, UnitCount},
+			{"ContainersSize", 30, UnitBytes},
+			{"ContainersReclaimableSize", 20, UnitBytes},
+			{"VolumesCount", 4, UnitCount},
+			{"VolumesSize", 150, UnitBytes},
+			{"VolumesReclaimableSize", 50, UnitBytes},
+		}
+		for i, e := range expected {
+			assert.Equal(t, e.name, data[i].Name)
+			assert.Equal(t, e.value, data[i].Value, e.name)
+			assert.Equal(t, string(e.unit), string(data[i].Unit))
+		}
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("build cache", func(t *testing.T) {
+		mockClient := new(DockerMockClient)
+		mockClient.On("getJSON", "1.39", "/system/df").Return(`{
+			"LayersSize": 1000,
+			"Images": [{"Containers": 1, "VirtualSize": 400, "SharedSize": 100}],
+			"BuildCache": [{"Size": 100, "InUse": true}, {"Size": 50, "InUse": false}]
+		}`, nil)
+
+		d := DockerDiskUsage{dockerMetric: dockerMetric{client: mockClient, apiVersion: "1.41"}}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 11)
+		assert.Equal(t, 1.0, data[0].Value)
+		assert.Equal(t, 1000.0, data[1].Value)
+		assert.Equal(t, 700.0, data[2].Value)
+		assert.Equal(t, "BuildCacheSize", data[9].Name)
+		assert.Equal(t, 150.0, data[9].Value)
+		assert.Equal(t, "BuildCacheReclaimableSize", data[10].Name)
+		assert.Equal(t, 50.0, data[10].Value)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("error for disk usage with build cache", func(t *testing.T) {
+		mockClient := new(DockerMockClient)
+		mockClient.On("getJSON", "1.39", "/system/df").Return(nil, errors.New("an error"))
+
+		d := DockerDiskUsage{dockerMetric: dockerMetric{client: mockClient, apiVersion: "1.41"}}
+		data, err := d.Gather()
+
+		assert.Error(t, err)
+		assert.Len(t, data, 0)
+	})
+
+	t.Run("error for disk usage", func(t *testing.T) {
+		mockClient := new(DockerMockClient)
+		mockClient.On("DiskUsage").Return(types.DiskUsage{}, errors.New("an error"))
+
+		d := DockerDiskUsage{dockerMetric: dockerMetric{client: mockClient}}
+		data, err := d.Gather()
+
+		assert.Error(t, err)
+		assert.Len(t, data, 0)
+		mockClient.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const dockerPingTimeout = 5 * time.Second
+
+// DockerEndpoint describes the docker daemon, or the daemon exposing a docker compatible API like podman,
+// the docker metrics connect to.
+// Host is the address of the daemon, e.g. unix:///var/run/docker.sock, unix:///run/podman/podman.sock or
+// tcp://10.0.0.1:2376, DOCKER_HOST when empty. When Host is empty the TLS configuration is read from the
+// DOCKER_CERT_PATH and DOCKER_TLS_VERIFY environment variables.
+// APIVersion pins the version of the API used, DOCKER_API_VERSION when empty, otherwise the version is
+// negotiated with the daemon.
+// TLSCACert, TLSCert and TLSKey are the paths of the certificates used to connect to a daemon over TLS.
+// The daemon certificate is only verified when TLSVerify is set.
+type DockerEndpoint struct {
+	Host       string
+	APIVersion string
+	TLSCACert  string
+	TLSCert    string
+	TLSKey     string
+	TLSVerify  bool
+}
+
+func (e DockerEndpoint) useTLS() bool {
+	return e.TLSCACert != "" || e.TLSCert != "" || e.TLSKey != "" || e.TLSVerify
+}
+
+// host returns the address of the daemon, from DOCKER_HOST if not set
+func (e DockerEndpoint) host() string {
+	if e.Host != "" {
+		return e.Host
+	}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return client.DefaultDockerHost
+}
+
+// apiVersion returns the API version pinned by APIVersion or DOCKER_API_VERSION, empty if not pinned
+func (e DockerEndpoint) apiVersion() string {
+	if e.APIVersion != "" {
+		return e.APIVersion
+	}
+	return os.Getenv("DOCKER_API_VERSION")
+}
+
+// tlsOptions returns the TLS configuration of the endpoint, from DOCKER_CERT_PATH and DOCKER_TLS_VERIFY
+// for the daemon given by DOCKER_HOST, or nil if TLS is not used
+func (e DockerEndpoint) tlsOptions() *tlsconfig.Options {
+	if e.useTLS() {
+		return &tlsconfig.Options{
+			CAFile:             e.TLSCACert,
+			CertFile:           e.TLSCert,
+			KeyFile:            e.TLSKey,
+			InsecureSkipVerify: !e.TLSVerify,
+		}
+	}
+	if certPath := os.Getenv("DOCKER_CERT_PATH"); e.Host == "" && certPath != "" {
+		return &tlsconfig.Options{
+			CAFile:             filepath.Join(certPath, "ca.pem"),
+			CertFile:           filepath.Join(certPath, "cert.pem"),
+			KeyFile:            filepath.Join(certPath, "key.pem"),
+			InsecureSkipVerify: os.Getenv("DOCKER_TLS_VERIFY") == "",
+		}
+	}
+	return nil
+}
+
+// dockerClient is the client used by the docker metrics: the docker client together with raw requests
+// for the parts of the API that the docker client in use predates, e.g. the build cache
+type dockerClient interface {
+	client.CommonAPIClient
+
+	// getJSON decodes into v the response to a GET request for the path of the given version of the API
+	getJSON(ctx context.Context, version, path string, v interface{}) error
+}
+
+// dockerAPIClient is a docker client sending the raw requests with the HTTP client of the docker client
+type dockerAPIClient struct {
+	*client.Client
+
+	http *http.Client
+	url  string
+}
+
+func (c *dockerAPIClient) getJSON(ctx context.Context, version, path string, v interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, c.url+"/v"+strings.TrimPrefix(version, "v")+path, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create docker request [%s]", path)
+	}
+	response, err := c.http.Do(request.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to send docker request [%s]", path)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return errors.Errorf("failed to send docker request [%s]: unexpected status [%s]: %s",
+			path, response.Status, strings.TrimSpace(string(message)))
+	}
+	return errors.Wrapf(json.NewDecoder(response.Body).Decode(v), "failed to decode docker response [%s]", path)
+}
+
+// newClient creates a docker client connected to the endpoint. Settings not given by the endpoint are read
+// from the DOCKER_HOST, DOCKER_API_VERSION, DOCKER_CERT_PATH and DOCKER_TLS_VERIFY environment variables.
+func (e DockerEndpoint) newClient() (*dockerAPIClient, error) {
+	host := e.host()
+	proto, addr, basePath, err := client.ParseHost(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create docker client for host [%s]", host)
+	}
+
+	scheme := "http"
+	transport := &http.Transport{}
+	if options := e.tlsOptions(); options != nil {
+		tlsConfig, err := tlsconfig.Client(*options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load TLS configuration for docker host [%s]", host)
+		}
+		transport.TLSClientConfig = tlsConfig
+		scheme = "https"
+	}
+	if err := sockets.ConfigureTransport(transport, proto, addr); err != nil {
+		return nil, errors.Wrapf(err, "failed to create docker client for host [%s]", host)
+	}
+	httpClient := &http.Client{Transport: transport}
+
+	version := e.apiVersion()
+	if version == "" {
+		version = client.DefaultVersion
+	}
+	cli, err := client.NewClient(host, version, httpClient, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create docker client for host [%s]", host)
+	}
+
+	if proto != "tcp" {
+		// sockets and named pipes are dialled by the transport, the host of the URL is only a placeholder
+		addr = "docker"
+	}
+	return &dockerAPIClient{Client: cli, http: httpClient, url: scheme + "://" + addr + basePath}, nil
+}
+
+// negotiateAPIVersion downgrades the API version used by the client when the daemon only supports an older
+// version, e.g. older docker releases or podman, and returns the API version of the daemon. The version is
+// left unchanged, and returned, if the daemon cannot be reached so that the error is reported by the
+// following request.
+func negotiateAPIVersion(cli client.CommonAPIClient) string {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerPingTimeout)
+	defer cancel()
+
+	ping, err := cli.Ping(ctx)
+	if err != nil || ping.APIVersion == "" {
+		log.Debugf("failed to negotiate docker API version: %v", err)
+		return cli.ClientVersion()
+	}
+
+	if versions.LessThan(ping.APIVersion, cli.ClientVersion()) {
+		log.Debugf("downgrading docker API version from [%s] to [%s]", cli.ClientVersion(), ping.APIVersion)
+		cli.UpdateClientVersion(ping.APIVersion)
+	}
+	return ping.APIVersion
+}
+
+// hostDimensions returns the DockerHost dimension used to tell apart the data points of different daemons
+// when an explicit host is configured
+func (e DockerEndpoint) hostDimensions() []Dimension {
+	if e.Host == "" {
+		return []Dimension{}
+	}
+	hostDim, _ := NewDimension("DockerHost", e.Host)
+	return []Dimension{hostDim}
+}
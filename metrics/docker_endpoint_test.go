@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDockerEndpoint_NewClient(t *testing.T) {
+	t.Run("from environment variables", func(t *testing.T) {
+		cli, err := DockerEndpoint{}.newClient()
+		assert.NoError(t, err)
+		assert.NotNil(t, cli)
+	})
+
+	t.Run("host from environment variables with explicit API version", func(t *testing.T) {
+		host := os.Getenv("DOCKER_HOST")
+		defer os.Setenv("DOCKER_HOST", host)
+		os.Setenv("DOCKER_HOST", "tcp://10.0.0.1:2375")
+
+		cli, err := DockerEndpoint{APIVersion: "1.24"}.newClient()
+		assert.NoError(t, err)
+		assert.Equal(t, "http://10.0.0.1:2375", cli.url)
+		assert.Equal(t, "1.24", cli.ClientVersion())
+	})
+
+	t.Run("explicit host and API version", func(t *testing.T) {
+		cli, err := DockerEndpoint{Host: "unix:///run/podman/podman.sock", APIVersion: "1.24"}.newClient()
+		assert.NoError(t, err)
+		assert.Equal(t, "1.24", cli.ClientVersion())
+	})
+
+	t.Run("invalid host", func(t *testing.T) {
+		_, err := DockerEndpoint{Host: "localhost"}.newClient()
+		assert.Error(t, err)
+	})
+
+	t.Run("missing TLS certificates", func(t *testing.T) {
+		_, err := DockerEndpoint{Host: "tcp://localhost:2376", TLSCACert: "testdata/missing/ca.pem", TLSVerify: true}.newClient()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "TLS")
+	})
+}
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	t.Run("downgrade to an older daemon", func(t *testing.T) {
+		mockClient := &DockerMockClient{}
+		mockClient.On("Ping").Return(types.Ping{APIVersion: "1.24"}, nil)
+		mockClient.On("ClientVersion").Return("1.25")
+		mockClient.On("UpdateClientVersion", "1.24").Return()
+
+		assert.Equal(t, "1.24", negotiateAPIVersion(mockClient))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("keep version for a newer daemon", func(t *testing.T) {
+		mockClient := &DockerMockClient{}
+		mockClient.On("Ping").Return(types.Ping{APIVersion: "1.40"}, nil)
+		mockClient.On("ClientVersion").Return("1.25")
+
+		assert.Equal(t, "1.40", negotiateAPIVersion(mockClient))
+		mockClient.AssertExpectations(t)
+		mockClient.AssertNotCalled(t, "UpdateClientVersion", "1.40")
+	})
+
+	t.Run("keep version if the daemon is unreachable", func(t *testing.T) {
+		mockClient := &DockerMockClient{}
+		mockClient.On("Ping").Return(types.Ping{}, errors.New("error"))
+		mockClient.On("ClientVersion").Return("1.25")
+
+		assert.Equal(t, "1.25", negotiateAPIVersion(mockClient))
+		mockClient.AssertExpectations(t)
+		mockClient.AssertNotCalled(t, "UpdateClientVersion", mock.Anything)
+	})
+}
+
+func TestDockerEndpoint_HostDimensions(t *testing.T) {
+	assert.Equal(t, []Dimension{}, DockerEndpoint{}.hostDimensions())
+	assert.Equal(
+		t,
+		[]Dimension{{Name: "DockerHost", Value: "tcp://10.0.0.1:2376"}},
+		DockerEndpoint{Host: "tcp://10.0.0.1:2376"}.hostDimensions())
+}
+
+func TestDockerAPIClient_getJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/base/v1.39/info" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("client version 1.40 is too new"))
+			return
+		}
+		w.Write([]byte(`{"Name": "docker"}`))
+	}))
+	defer server.Close()
+
+	cli, err := DockerEndpoint{Host: "tcp://" + strings.TrimPrefix(server.URL, "http://") + "/base"}.newClient()
+	assert.NoError(t, err)
+
+	var info struct{ Name string }
+	assert.NoError(t, cli.getJSON(context.Background(), "1.39", "/info", &info))
+	assert.Equal(t, "docker", info.Name)
+
+	err = cli.getJSON(context.Background(), "1.40", "/info", &info)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "too new")
+}
+
+func TestDockerEndpoint_RemoteHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_ping":
+			w.Header().Set("API-Version", "1.24")
+			w.Write([]byte("OK"))
+		case "/v1.24/system/df":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"LayersSize": 0, "Images": [], "Containers": [], "Volumes": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := "tcp://" + strings.TrimPrefix(server.URL, "http://")
+	d := DockerDiskUsage{}
+	d.Endpoint = DockerEndpoint{Host: host}
+	data, err := d.Gather()
+
+	assert.NoError(t, err)
+	assert.Len(t, data, 9)
+	for _, p := range data {
+		assert.Equal(t, []Dimension{{Name: "DockerHost", Value: host}}, p.Dimensions)
+	}
+}
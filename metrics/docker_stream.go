@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// containerStream tracks the streaming statistics connection of a single container
+type containerStream struct {
+	cancel   context.CancelFunc
+	latest   *types.StatsJSON
+	cpuQuota float64
+}
+
+// DockerStatStream collects docker statistics from the running containers keeping a streaming
+// statistics connection open for every container. Containers are tracked as they start and stop
+// and the latest sample received for every container is kept in memory so that Gather does not
+// have to wait for the docker daemon to sample the statistics.
+type DockerStatStream struct {
+	DockerStat
+
+	mu      sync.Mutex
+	streams map[string]*containerStream
+}
+
+// NewDockerStatStream creates a streaming collector reporting the same data points of the given DockerStat
+func NewDockerStatStream(stat DockerStat) *DockerStatStream {
+	return &DockerStatStream{DockerStat: stat, streams: map[string]*containerStream{}}
+}
+
+// Name of the DockerStatStream metric
+func (d *DockerStatStream) Name() string {
+	return "docker-stat"
+}
+
+// sync starts a statistics stream for every new container and stops the streams for the containers
+// that are no longer running
+func (d *DockerStatStream) sync(containers []types.Container) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	running := map[string]bool{}
+	for _, container := range containers {
+		running[container.ID] = true
+		if _, ok := d.streams[container.ID]; ok {
+			continue
+		}
+
+		log.Debugf("starting statistics stream for container ID [%s]", container.ID)
+		ctx, cancel := context.WithCancel(context.Background())
+		s := &containerStream{cancel: cancel}
+		d.streams[container.ID] = s
+		go d.stream(ctx, container.ID, s)
+	}
+
+	for id, s := range d.streams {
+		if !running[id] {
+			log.Debugf("stopping statistics stream for container ID [%s]", id)
+			s.cancel()
+			delete(d.streams, id)
+		}
+	}
+}
+
+// stream reads the statistics for the given container until the stream is closed by the docker daemon,
+// e.g. because the container stopped, or it is cancelled
+func (d *DockerStatStream) stream(ctx context.Context, containerID string, s *containerStream) {
+	defer d.remove(containerID, s)
+
+	response, err := d.client.ContainerStats(ctx, containerID, true)
+	if err != nil {
+		log.Warnf("failed to stream statistics for container ID [%s]: %s", containerID, err)
+		return
+	}
+	defer response.Body.Close()
+
+	dec := json.NewDecoder(response.Body)
+	quotaFetched := false
+	for {
+		var v types.StatsJSON
+		if err := dec.Decode(&v); err != nil {
+			if ctx.Err() == nil {
+				log.Debugf("statistics stream for container ID [%s] closed: %s", containerID, err)
+			}
+			return
+		}
+
+		// the quota cannot change without restarting the container hence it is looked up only once
+		var cpuQuota float64
+		if !quotaFetched && v.CPUStats.ThrottlingData.Periods > 0 {
+			quotaFetched = true
+			if cpuQuota, err = d.getCPUQuota(ctx, containerID); err != nil {
+				log.Warnf("failed to fetch the CPU quota for container ID [%s]: %s", containerID, err)
+			}
+		}
+
+		d.mu.Lock()
+		s.latest = &v
+		if cpuQuota > 0 {
+			s.cpuQuota = cpuQuota
+		}
+		d.mu.Unlock()
+	}
+}
+
+// remove the given stream if it is still the one tracked for the container
+func (d *DockerStatStream) remove(containerID string, s *containerStream) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s.cancel()
+	if d.streams[containerID] == s {
+		delete(d.streams, containerID)
+	}
+}
+
+// latest returns the containers for which a sample has been received together with their latest sample
+func (d *DockerStatStream) latest(containers []types.Container) ([]types.Container, []statsResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sampled := make([]types.Container, 0, len(containers))
+	results := make([]statsResult, 0, len(containers))
+	for _, container := range containers {
+		s, ok := d.streams[container.ID]
+		if !ok || s.latest == nil {
+			log.Debugf("no statistics received yet for container ID [%s]", container.ID)
+			continue
+		}
+		sampled = append(sampled, container)
+		results = append(results, statsResult{stats: *s.latest, cpuQuota: s.cpuQuota})
+	}
+	return sampled, results
+}
+
+// Gather the latest statistics received from the running containers. It returns the same data points
+// of DockerStat or error if the list of containers cannot be fetched. Containers for which no statistics
+// have been received yet, e.g. because they just started, are not reported.
+func (d *DockerStatStream) Gather() (Data, error) {
+	log.Debug("gathering streamed docker stats")
+
+	if err := d.initClient(); err != nil {
+		return Data{}, err
+	}
+
+	containers, err := d.client.ContainerList(context.Background(), types.ContainerListOptions{All: false})
+	if err != nil {
+		return Data{}, errors.Wrap(err, "failed to list containers")
+	}
+
+	d.sync(containers)
+	sampled, results := d.latest(containers)
+	data := d.statsToData(sampled, results)
+	data.AddDimensions(d.Endpoint.hostDimensions()...)
+	return data, nil
+}
+
+// Close all the open statistics streams
+func (d *DockerStatStream) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for id, s := range d.streams {
+		s.cancel()
+		delete(d.streams, id)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func makeStreamedStats(numCPUs int, preTotalUsage, preSystemUsage, totalUsage, systemUsage, memoryUsage uint64) types.StatsJSON {
+	stats := types.StatsJSON{}
+	stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, numCPUs)
+	stats.PreCPUStats.CPUUsage.TotalUsage = preTotalUsage
+	stats.PreCPUStats.SystemUsage = preSystemUsage
+	stats.CPUStats.CPUUsage.TotalUsage = totalUsage
+	stats.CPUStats.SystemUsage = systemUsage
+	stats.MemoryStats.Usage = memoryUsage
+	return stats
+}
+
+func numStreams(d *DockerStatStream) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.streams)
+}
+
+// gatherUntil gathers data from the stream collector until the given condition is met
+func gatherUntil(t *testing.T, d *DockerStatStream, condition func(Data) bool) Data {
+	for i := 0; i < 100; i++ {
+		data, err := d.Gather()
+		assert.NoError(t, err)
+		if condition(data) {
+			return data
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("condition not met while gathering data")
+	return nil
+}
+
+func TestDockerStatStream_Name(t *testing.T) {
+	d := NewDockerStatStream(DockerStat{})
+	assert.Equal(t, "docker-stat", d.Name())
+}
+
+func TestDockerStatStream_Gather(t *testing.T) {
+	t.Run("reports the latest streamed sample", func(t *testing.T) {
+		containerId := "c1"
+		reader, writer := io.Pipe()
+		defer writer.Close()
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{makeContainer(containerId)}, nil)
+		mockClient.On("ContainerStats", containerId, true).
+			Return(types.ContainerStats{Body: reader}, nil).Once()
+
+		d := NewDockerStatStream(DockerStat{dockerMetric: dockerMetric{client: mockClient}})
+		defer d.Close()
+
+		data, err := d.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 0)
+
+		enc := json.NewEncoder(writer)
+		assert.NoError(t, enc.Encode(makeStreamedStats(2, 100, 1000, 150, 1200, 300)))
+		data = gatherUntil(t, d, func(data Data) bool { return len(data) == 2 })
+
+		assert.Equal(t, "CPUUtilization", data[0].Name)
+		assert.Equal(t, 50.0, data[0].Value)
+		assert.Equal(t, makeContainerDimensions(containerId), data[0].Dimensions)
+		assert.Equal(t, "MemoryUtilization", data[1].Name)
+		assert.Equal(t, 300.0, data[1].Value)
+
+		assert.NoError(t, enc.Encode(makeStreamedStats(2, 150, 1200, 250, 1400, 500)))
+		data = gatherUntil(t, d, func(data Data) bool { return len(data) == 2 && data[1].Value == 500.0 })
+		assert.Equal(t, 100.0, data[0].Value)
+		assert.Equal(t, 500.0, data[1].Value)
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("stops tracking stopped containers", func(t *testing.T) {
+		containerId := "c1"
+		reader, writer := io.Pipe()
+		defer writer.Close()
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{makeContainer(containerId)}, nil).Once()
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{}, nil).Once()
+		streaming := make(chan bool)
+		mockClient.On("ContainerStats", containerId, true).
+			Return(types.ContainerStats{Body: reader}, nil).Once().
+			Run(func(mock.Arguments) { close(streaming) })
+
+		d := NewDockerStatStream(DockerStat{dockerMetric: dockerMetric{client: mockClient}})
+		defer d.Close()
+
+		_, err := d.Gather()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, numStreams(d))
+		<-streaming
+
+		data, err := d.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 0)
+		assert.Equal(t, 0, numStreams(d))
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("restarts closed streams", func(t *testing.T) {
+		containerId := "c1"
+		stats, _ := json.Marshal(makeStreamedStats(2, 100, 1000, 150, 1200, 300))
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{makeContainer(containerId)}, nil)
+		mockClient.On("ContainerStats", containerId, true).
+			Return(types.ContainerStats{Body: ioutil.NopCloser(bytes.NewReader(stats))}, nil).Once()
+		reader, writer := io.Pipe()
+		defer writer.Close()
+		streaming := make(chan bool)
+		mockClient.On("ContainerStats", containerId, true).
+			Return(types.ContainerStats{Body: reader}, nil).Once().
+			Run(func(mock.Arguments) { close(streaming) })
+
+		d := NewDockerStatStream(DockerStat{dockerMetric: dockerMetric{client: mockClient}})
+		defer d.Close()
+
+		_, err := d.Gather()
+		assert.NoError(t, err)
+		for i := 0; i < 100 && numStreams(d) > 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, 0, numStreams(d))
+
+		_, err = d.Gather()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, numStreams(d))
+		<-streaming
+
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestDockerStatStream_Close(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	mockClient := new(DockerMockClient)
+	mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+		Return([]types.Container{makeContainer("c1")}, nil)
+	streaming := make(chan bool)
+	mockClient.On("ContainerStats", "c1", true).Return(types.ContainerStats{Body: reader}, nil).
+		Run(func(mock.Arguments) { close(streaming) })
+
+	d := NewDockerStatStream(DockerStat{dockerMetric: dockerMetric{client: mockClient}})
+	_, err := d.Gather()
+	assert.NoError(t, err)
+	<-streaming
+
+	assert.NoError(t, d.Close())
+	assert.Equal(t, 0, numStreams(d))
+}
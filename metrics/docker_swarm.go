@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const stackLabel = "com.docker.stack.namespace"
+
+// DockerSwarm collects the state of the services and nodes of a docker swarm.
+// It must run on a swarm manager node since only managers can list services, tasks and nodes.
+type DockerSwarm struct {
+	dockerMetric
+}
+
+// Name of the DockerSwarm metric
+func (d *DockerSwarm) Name() string {
+	return "docker-swarm"
+}
+
+// GetDimensionsFromService is a utility function to construct dimensions from a swarm service.
+// It creates a Dimension with name Service and value given by the name of the service and,
+// if the service was deployed as part of a stack, a Dimension with name Stack and value given by
+// the name of the stack
+func GetDimensionsFromService(service swarm.Service) []Dimension {
+	serviceDim, _ := NewDimension("Service", service.Spec.Name)
+	dimensions := []Dimension{serviceDim}
+	if stack, ok := service.Spec.Labels[stackLabel]; ok {
+		stackDim, _ := NewDimension("Stack", stack)
+		dimensions = append(dimensions, stackDim)
+	}
+	return dimensions
+}
+
+// runningTasksFilter filters the tasks the orchestrator wants to run
+func runningTasksFilter() filters.Args {
+	f := filters.NewArgs()
+	f.Add("desired-state", string(swarm.TaskStateRunning))
+	return f
+}
+
+func (d *DockerSwarm) gatherServices() (Data, error) {
+	services, err := d.client.ServiceList(context.Background(), types.ServiceListOptions{})
+	if err != nil {
+		return Data{}, errors.Wrap(err, "failed to list services")
+	}
+
+	// the tasks of the previous versions of the services, kept by swarm as history, are not desired to run
+	tasks, err := d.client.TaskList(context.Background(), types.TaskListOptions{Filters: runningTasksFilter()})
+	if err != nil {
+		return Data{}, errors.Wrap(err, "failed to list tasks")
+	}
+
+	tasksByService := map[string][]swarm.Task{}
+	for _, task := range tasks {
+		tasksByService[task.ServiceID] = append(tasksByService[task.ServiceID], task)
+	}
+
+	data := Data{}
+	for _, service := range services {
+		dimensions := GetDimensionsFromService(service)
+
+		desired, running := len(tasksByService[service.ID]), 0
+		tasksByState := map[swarm.TaskState]int{}
+		for _, task := range tasksByService[service.ID] {
+			tasksByState[task.Status.State]++
+			if task.Status.State == swarm.TaskStateRunning {
+				running++
+			}
+		}
+		// for global services the number of desired tasks is given by the tasks
+		// the orchestrator wants to run on the eligible nodes
+		if service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil {
+			desired = int(*service.Spec.Mode.Replicated.Replicas)
+		}
+
+		desiredTasks := NewDataPoint("DesiredTasks", float64(desired), UnitCount, dimensions...)
+		runningTasks := NewDataPoint("RunningTasks", float64(running), UnitCount, dimensions...)
+		data = append(data, &desiredTasks, &runningTasks)
+
+		states := make([]string, 0, len(tasksByState))
+		for state := range tasksByState {
+			states = append(states, string(state))
+		}
+		sort.Strings(states)
+		for _, state := range states {
+			stateDim, _ := NewDimension("State", state)
+			stateTasks := NewDataPoint("Tasks", float64(tasksByState[swarm.TaskState(state)]), UnitCount,
+				append(dimensions, stateDim)...)
+			data = append(data, &stateTasks)
+		}
+	}
+
+	return data, nil
+}
+
+func (d *DockerSwarm) gatherNodes() (Data, error) {
+	nodes, err := d.client.NodeList(context.Background(), types.NodeListOptions{})
+	if err != nil {
+		return Data{}, errors.Wrap(err, "failed to list nodes")
+	}
+
+	nodesByState := map[swarm.NodeState]int{}
+	nodesByAvailability := map[swarm.NodeAvailability]int{}
+	for _, node := range nodes {
+		nodesByState[node.Status.State]++
+		nodesByAvailability[node.Spec.Availability]++
+	}
+
+	nodesReady := NewDataPoint("NodesReady", float64(nodesByState[swarm.NodeStateReady]), UnitCount)
+	nodesDown := NewDataPoint("NodesDown", float64(nodesByState[swarm.NodeStateDown]), UnitCount)
+	nodesDisconnected := NewDataPoint("NodesDisconnected", float64(nodesByState[swarm.NodeStateDisconnected]), UnitCount)
+	nodesUnknown := NewDataPoint("NodesUnknown", float64(nodesByState[swarm.NodeStateUnknown]), UnitCount)
+	nodesActive := NewDataPoint("NodesActive", float64(nodesByAvailability[swarm.NodeAvailabilityActive]), UnitCount)
+	nodesPaused := NewDataPoint("NodesPaused", float64(nodesByAvailability[swarm.NodeAvailabilityPause]), UnitCount)
+	nodesDrained := NewDataPoint("NodesDrained", float64(nodesByAvailability[swarm.NodeAvailabilityDrain]), UnitCount)
+	return Data([]*Point{
+		&nodesReady, &nodesDown, &nodesDisconnected, &nodesUnknown,
+		&nodesActive, &nodesPaused, &nodesDrained,
+	}), nil
+}
+
+// Gather the state of the swarm services and nodes or error if services, tasks or nodes cannot be listed,
+// e.g. because the docker daemon is not a swarm manager. It will return the following data points
+// for every service, with the Service and Stack dimensions
+// - DesiredTasks (count)
+// - RunningTasks (count)
+// - Tasks (count) number of tasks desired to run in each state, with an additional State dimension
+// and the following data points for the nodes of the swarm
+// - NodesReady, NodesDown, NodesDisconnected, NodesUnknown (count)
+// - NodesActive, NodesPaused, NodesDrained (count)
+func (d *DockerSwarm) Gather() (Data, error) {
+	log.Debug("gathering docker swarm state")
+
+	if err := d.initClient(); err != nil {
+		return Data{}, err
+	}
+
+	data, err := d.gatherServices()
+	if err != nil {
+		return Data{}, err
+	}
+
+	nodesData, err := d.gatherNodes()
+	if err != nil {
+		return Data{}, err
+	}
+
+	data = append(data, nodesData...)
+	data.AddDimensions(d.Endpoint.hostDimensions()...)
+	return data, nil
+}
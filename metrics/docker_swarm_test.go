@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeReplicatedService(id, name string, replicas uint64, labels map[string]string) swarm.Service {
+	service := swarm.Service{ID: id}
+	service.Spec.Name = name
+	service.Spec.Labels = labels
+	service.Spec.Mode.Replicated = &swarm.ReplicatedService{Replicas: &replicas}
+	return service
+}
+
+func makeGlobalService(id, name string) swarm.Service {
+	service := swarm.Service{ID: id}
+	service.Spec.Name = name
+	service.Spec.Mode.Global = &swarm.GlobalService{}
+	return service
+}
+
+func makeTask(serviceID string, state, desiredState swarm.TaskState) swarm.Task {
+	return swarm.Task{ServiceID: serviceID, Status: swarm.TaskStatus{State: state}, DesiredState: desiredState}
+}
+
+func makeNode(state swarm.NodeState, availability swarm.NodeAvailability) swarm.Node {
+	node := swarm.Node{}
+	node.Status.State = state
+	node.Spec.Availability = availability
+	return node
+}
+
+func findPoint(data Data, name string, dimensions ...Dimension) *Point {
+	for _, p := range data {
+		sameDimensions := len(dimensions) == 0 && len(p.Dimensions) == 0 || assert.ObjectsAreEqual(dimensions, p.Dimensions)
+		if p.Name == name && sameDimensions {
+			return p
+		}
+	}
+	return nil
+}
+
+func TestGetDimensionsFromService(t *testing.T) {
+	t.Run("service without stack", func(t *testing.T) {
+		dims := GetDimensionsFromService(makeGlobalService("id", "web"))
+		assert.Equal(t, []Dimension{{Name: "Service", Value: "web"}}, dims)
+	})
+
+	t.Run("service deployed in a stack", func(t *testing.T) {
+		service := makeReplicatedService("id", "shop_web", 1, map[string]string{stackLabel: "shop"})
+		dims := GetDimensionsFromService(service)
+		assert.Equal(t, []Dimension{{Name: "Service", Value: "shop_web"}, {Name: "Stack", Value: "shop"}}, dims)
+	})
+}
+
+func TestDockerSwarm_Name(t *testing.T) {
+	d := DockerSwarm{}
+	assert.Equal(t, "docker-swarm", d.Name())
+}
+
+func TestDockerSwarm_Gather(t *testing.T) {
+	t.Run("services and nodes", func(t *testing.T) {
+		services := []swarm.Service{
+			makeReplicatedService("s1", "shop_web", 3, map[string]string{stackLabel: "shop"}),
+			makeGlobalService("s2", "agent"),
+		}
+		tasks := []swarm.Task{
+			makeTask("s1", swarm.TaskStateRunning, swarm.TaskStateRunning),
+			makeTask("s1", swarm.TaskStateRunning, swarm.TaskStateRunning),
+			makeTask("s1", swarm.TaskStatePending, swarm.TaskStateRunning),
+			makeTask("s2", swarm.TaskStateRunning, swarm.TaskStateRunning),
+			makeTask("s2", swarm.TaskStateStarting, swarm.TaskStateRunning),
+		}
+		nodes := []swarm.Node{
+			makeNode(swarm.NodeStateReady, swarm.NodeAvailabilityActive),
+			makeNode(swarm.NodeStateReady, swarm.NodeAvailabilityDrain),
+			makeNode(swarm.NodeStateDown, swarm.NodeAvailabilityActive),
+		}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ServiceList", types.ServiceListOptions{}).Return(services, nil)
+		mockClient.On("TaskList", types.TaskListOptions{Filters: runningTasksFilter()}).Return(tasks, nil)
+		mockClient.On("NodeList", types.NodeListOptions{}).Return(nodes, nil)
+
+		d := DockerSwarm{dockerMetric: dockerMetric{client: mockClient}}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 15)
+
+		webDims := []Dimension{{Name: "Service", Value: "shop_web"}, {Name: "Stack", Value: "shop"}}
+		assert.Equal(t, 3.0, findPoint(data, "DesiredTasks", webDims...).Value)
+		assert.Equal(t, 2.0, findPoint(data, "RunningTasks", webDims...).Value)
+		assert.Equal(t, 2.0, findPoint(data, "Tasks", append(webDims, Dimension{"State", "running"})...).Value)
+		assert.Equal(t, 1.0, findPoint(data, "Tasks", append(webDims, Dimension{"State", "pending"})...).Value)
+		assert.Nil(t, findPoint(data, "Tasks", append(webDims, Dimension{"State", "failed"})...))
+
+		agentDims := []Dimension{{Name: "Service", Value: "agent"}}
+		assert.Equal(t, 2.0, findPoint(data, "DesiredTasks", agentDims...).Value)
+		assert.Equal(t, 1.0, findPoint(data, "RunningTasks", agentDims...).Value)
+		assert.Equal(t, 1.0, findPoint(data, "Tasks", append(agentDims, Dimension{"State", "starting"})...).Value)
+
+		assert.Equal(t, 2.0, findPoint(data, "NodesReady").Value)
+		assert.Equal(t, 1.0, findPoint(data, "NodesDown").Value)
+		assert.Equal(t, 0.0, findPoint(data, "NodesDisconnected").Value)
+		assert.Equal(t, 2.0, findPoint(data, "NodesActive").Value)
+		assert.Equal(t, 1.0, findPoint(data, "NodesDrained").Value)
+		assert.Equal(t, 0.0, findPoint(data, "NodesPaused").Value)
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("error for service list", func(t *testing.T) {
+		mockClient := new(DockerMockClient)
+		mockClient.On("ServiceList", types.ServiceListOptions{}).Return([]swarm.Service{}, errors.New("an error"))
+
+		d := DockerSwarm{dockerMetric: dockerMetric{client: mockClient}}
+		data, err := d.Gather()
+
+		assert.Error(t, err)
+		assert.Len(t, data, 0)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("error for node list", func(t *testing.T) {
+		mockClient := new(DockerMockClient)
+		mockClient.On("ServiceList", types.ServiceListOptions{}).Return([]swarm.Service{}, nil)
+		mockClient.On("TaskList", types.TaskListOptions{Filters: runningTasksFilter()}).Return([]swarm.Task{}, nil)
+		mockClient.On("NodeList", types.NodeListOptions{}).Return([]swarm.Node{}, errors.New("an error"))
+
+		d := DockerSwarm{dockerMetric: dockerMetric{client: mockClient}}
+		data, err := d.Gather()
+
+		assert.Error(t, err)
+		assert.Len(t, data, 0)
+		mockClient.AssertExpectations(t)
+	})
+}
@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -17,16 +20,22 @@ import (
 
 type DockerMockClient struct {
 	mock.Mock
-	client.ContainerAPIClient
+	client.CommonAPIClient
 }
 
 func (m DockerMockClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
 	args := m.Called(options)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	return args.Get(0).([]types.Container), args.Error(1)
 }
 
 func (m DockerMockClient) ContainerStats(ctx context.Context, container string, stream bool) (types.ContainerStats, error) {
 	args := m.Called(container, stream)
+	if ctx.Err() != nil {
+		return types.ContainerStats{}, ctx.Err()
+	}
 	return args.Get(0).(types.ContainerStats), args.Error(1)
 }
 
@@ -35,10 +44,59 @@ func (m DockerMockClient) ContainerInspect(ctx context.Context, containerID stri
 	return args.Get(0).(types.ContainerJSON), args.Error(1)
 }
 
+func (m DockerMockClient) DiskUsage(ctx context.Context) (types.DiskUsage, error) {
+	args := m.Called()
+	return args.Get(0).(types.DiskUsage), args.Error(1)
+}
+
+func (m DockerMockClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	args := m.Called(options)
+	return args.Get(0).([]swarm.Service), args.Error(1)
+}
+
+func (m DockerMockClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	args := m.Called(options)
+	return args.Get(0).([]swarm.Task), args.Error(1)
+}
+
+func (m DockerMockClient) NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error) {
+	args := m.Called(options)
+	return args.Get(0).([]swarm.Node), args.Error(1)
+}
+
+func (m DockerMockClient) Ping(ctx context.Context) (types.Ping, error) {
+	args := m.Called()
+	return args.Get(0).(types.Ping), args.Error(1)
+}
+
+func (m DockerMockClient) getJSON(ctx context.Context, version, path string, v interface{}) error {
+	args := m.Called(version, path)
+	if body, ok := args.Get(0).(string); ok {
+		if err := json.Unmarshal([]byte(body), v); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m DockerMockClient) ClientVersion() string {
+	return m.Called().String(0)
+}
+
+func (m DockerMockClient) UpdateClientVersion(v string) {
+	m.Called(v)
+}
+
 func makeContainer(containerId string) types.Container {
 	return types.Container{ID: containerId, Names: []string{"name-" + containerId}}
 }
 
+func makeServiceContainer(containerId, service string) types.Container {
+	c := makeContainer(containerId)
+	c.Labels = map[string]string{"service": service}
+	return c
+}
+
 func makeContainerDimensions(id string) []Dimension {
 	return []Dimension{
 		{Name: "Container", Value: "name-" + id},
@@ -70,6 +128,149 @@ func makeContainerDetails(healthState string) types.ContainerJSON {
 	}
 }
 
+func TestComputeCpu(t *testing.T) {
+	t.Run("without previous sample", func(t *testing.T) {
+		stats := types.StatsJSON{}
+		stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, 2)
+		stats.CPUStats.CPUUsage.TotalUsage = 100
+		stats.CPUStats.SystemUsage = 400
+		assert.Equal(t, 50.0, computeCpu(stats))
+	})
+
+	t.Run("with previous sample", func(t *testing.T) {
+		stats := types.StatsJSON{}
+		stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, 2)
+		stats.PreCPUStats.CPUUsage.TotalUsage = 100
+		stats.PreCPUStats.SystemUsage = 400
+		stats.CPUStats.CPUUsage.TotalUsage = 400
+		stats.CPUStats.SystemUsage = 1000
+		assert.Equal(t, 100.0, computeCpu(stats))
+	})
+
+	t.Run("without system usage", func(t *testing.T) {
+		stats := types.StatsJSON{}
+		stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, 2)
+		stats.CPUStats.CPUUsage.TotalUsage = 100
+		assert.Equal(t, 0.0, computeCpu(stats))
+	})
+}
+
+func TestComputeThrottling(t *testing.T) {
+	dimensions := makeContainerDimensions("c1")
+
+	t.Run("without cpu quota", func(t *testing.T) {
+		data := computeThrottling(types.ThrottlingData{}, nil, 0, dimensions...)
+		assert.Len(t, data, 0)
+	})
+
+	t.Run("without previous sample", func(t *testing.T) {
+		throttling := types.ThrottlingData{Periods: 200, ThrottledPeriods: 50, ThrottledTime: 1500000000}
+		data := computeThrottling(throttling, nil, 0, dimensions...)
+
+		assert.Len(t, data, 3)
+		assert.Equal(t, "ThrottledPeriods", data[0].Name)
+		assert.Equal(t, string(UnitCount), string(data[0].Unit))
+		assert.Equal(t, 50.0, data[0].Value)
+		assert.Equal(t, "ThrottledTime", data[1].Name)
+		assert.Equal(t, string(UnitSeconds), string(data[1].Unit))
+		assert.Equal(t, 1.5, data[1].Value)
+		assert.Equal(t, "ThrottledPercentage", data[2].Name)
+		assert.Equal(t, string(UnitPercent), string(data[2].Unit))
+		assert.Equal(t, 25.0, data[2].Value)
+		assert.Equal(t, dimensions, data[2].Dimensions)
+	})
+
+	t.Run("with previous sample and cpu quota", func(t *testing.T) {
+		previous := types.ThrottlingData{Periods: 100, ThrottledPeriods: 40}
+		throttling := types.ThrottlingData{Periods: 110, ThrottledPeriods: 48}
+		data := computeThrottling(throttling, &previous, 1.5, dimensions...)
+
+		assert.Len(t, data, 4)
+		assert.Equal(t, "ThrottledPercentage", data[2].Name)
+		assert.Equal(t, 80.0, data[2].Value)
+		assert.Equal(t, "CPUQuotaCores", data[3].Name)
+		assert.Equal(t, string(UnitNone), string(data[3].Unit))
+		assert.Equal(t, 1.5, data[3].Value)
+		assert.Equal(t, dimensions, data[3].Dimensions)
+	})
+
+	t.Run("with counters reset since previous sample", func(t *testing.T) {
+		previous := types.ThrottlingData{Periods: 100, ThrottledPeriods: 40}
+		throttling := types.ThrottlingData{Periods: 10, ThrottledPeriods: 5}
+		data := computeThrottling(throttling, &previous, 0, dimensions...)
+
+		assert.Len(t, data, 3)
+		assert.Equal(t, 50.0, data[2].Value)
+	})
+}
+
+func TestDockerStat_getCPUQuota(t *testing.T) {
+	testCases := []struct {
+		name      string
+		resources container.Resources
+		expected  float64
+	}{
+		{name: "no quota", resources: container.Resources{}, expected: 0},
+		{name: "cpus", resources: container.Resources{NanoCPUs: 500000000}, expected: 0.5},
+		{name: "cfs quota", resources: container.Resources{CPUQuota: 200000, CPUPeriod: 100000}, expected: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			details := makeContainerDetails("")
+			details.HostConfig = &container.HostConfig{Resources: tc.resources}
+
+			mockClient := new(DockerMockClient)
+			mockClient.On("ContainerInspect", "c1").Return(details, nil)
+
+			d := DockerStat{dockerMetric: dockerMetric{client: mockClient}}
+			quota, err := d.getCPUQuota(context.Background(), "c1")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, quota)
+		})
+	}
+}
+
+func TestComputePids(t *testing.T) {
+	dimensions := makeContainerDimensions("c1")
+
+	t.Run("without pids stats", func(t *testing.T) {
+		data := computePids(types.StatsJSON{}, dimensions...)
+		assert.Len(t, data, 0)
+	})
+
+	t.Run("without pids limit", func(t *testing.T) {
+		stats := types.StatsJSON{}
+		stats.PidsStats.Current = 12
+		data := computePids(stats, dimensions...)
+
+		assert.Len(t, data, 1)
+		assert.Equal(t, "PidsCurrent", data[0].Name)
+		assert.Equal(t, string(UnitCount), string(data[0].Unit))
+		assert.Equal(t, 12.0, data[0].Value)
+		assert.Equal(t, dimensions, data[0].Dimensions)
+	})
+
+	t.Run("with pids limit", func(t *testing.T) {
+		stats := types.StatsJSON{}
+		stats.PidsStats.Current = 50
+		stats.PidsStats.Limit = 200
+		data := computePids(stats, dimensions...)
+
+		assert.Len(t, data, 3)
+		assert.Equal(t, "PidsCurrent", data[0].Name)
+		assert.Equal(t, 50.0, data[0].Value)
+		assert.Equal(t, "PidsLimit", data[1].Name)
+		assert.Equal(t, string(UnitCount), string(data[1].Unit))
+		assert.Equal(t, 200.0, data[1].Value)
+		assert.Equal(t, "PidsUtilization", data[2].Name)
+		assert.Equal(t, string(UnitPercent), string(data[2].Unit))
+		assert.Equal(t, 25.0, data[2].Value)
+		assert.Equal(t, dimensions, data[2].Dimensions)
+	})
+}
+
 func TestGetDimensionsFromContainer(t *testing.T) {
 	t.Run("uses label if requested", func(t *testing.T) {
 		labels := map[string]string{"label": "label"}
@@ -122,6 +323,15 @@ func TestDockerMetric_InitClient(t *testing.T) {
 		d.initClient()
 		assert.NotNil(t, d.client)
 	})
+
+	t.Run("the docker client is kept between gathers", func(t *testing.T) {
+		var m Metric = &DockerDiskUsage{}
+		m.Gather()
+		cli := m.(*DockerDiskUsage).client
+		assert.NotNil(t, cli)
+		m.Gather()
+		assert.True(t, cli == m.(*DockerDiskUsage).client)
+	})
 }
 
 func TestDockerStat_Name(t *testing.T) {
@@ -139,7 +349,9 @@ func TestDockerStat_Gather(t *testing.T) {
 		data, err := d.Gather()
 
 		assert.NoError(t, err)
-		assert.Len(t, data, 0)
+		assert.Len(t, data, 1)
+		assert.Equal(t, "ContainerStatsTimeouts", data[0].Name)
+		assert.Equal(t, 0.0, data[0].Value)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -174,11 +386,11 @@ func TestDockerStat_Gather(t *testing.T) {
 		data, err := d.Gather()
 
 		assert.NoError(t, err)
-		assert.Len(t, data, 4)
+		assert.Len(t, data, 5)
 
 		assert.Equal(t, data[0].Name, "CPUUtilization")
 		assert.Equal(t, string(data[0].Unit), string(UnitPercent))
-		assert.Equal(t, 1.0, data[0].Value)
+		assert.Equal(t, 100.0, data[0].Value)
 		assert.Equal(t, expectedDimensions1, data[0].Dimensions)
 
 		assert.Equal(t, data[1].Name, "MemoryUtilization")
@@ -188,7 +400,7 @@ func TestDockerStat_Gather(t *testing.T) {
 
 		assert.Equal(t, data[2].Name, "CPUUtilization")
 		assert.Equal(t, string(data[2].Unit), string(UnitPercent))
-		assert.Equal(t, 0.5, data[2].Value)
+		assert.Equal(t, 50.0, data[2].Value)
 		assert.Equal(t, expectedDimensions2, data[2].Dimensions)
 
 		assert.Equal(t, data[3].Name, "MemoryUtilization")
@@ -199,6 +411,260 @@ func TestDockerStat_Gather(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("stats with pids", func(t *testing.T) {
+		containerId := "c"
+		stats := types.StatsJSON{}
+		stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, 1)
+		stats.CPUStats.SystemUsage = 100
+		stats.PidsStats.Current = 10
+		stats.PidsStats.Limit = 100
+		b, _ := json.Marshal(stats)
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{makeContainer(containerId)}, nil)
+		mockClient.On("ContainerStats", containerId, false).
+			Return(types.ContainerStats{Body: ioutil.NopCloser(bytes.NewReader(b))}, nil)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 6)
+		assert.Equal(t, "PidsCurrent", data[2].Name)
+		assert.Equal(t, "PidsLimit", data[3].Name)
+		assert.Equal(t, "PidsUtilization", data[4].Name)
+		assert.Equal(t, 10.0, data[4].Value)
+		assert.Equal(t, makeContainerDimensions(containerId), data[4].Dimensions)
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("stats with cpu throttling", func(t *testing.T) {
+		containerId := "c"
+		stats := types.StatsJSON{}
+		stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, 1)
+		stats.CPUStats.SystemUsage = 100
+		stats.CPUStats.ThrottlingData = types.ThrottlingData{Periods: 10, ThrottledPeriods: 1}
+		b, _ := json.Marshal(stats)
+
+		details := makeContainerDetails("")
+		details.HostConfig = &container.HostConfig{Resources: container.Resources{NanoCPUs: 250000000}}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{makeContainer(containerId)}, nil)
+		mockClient.On("ContainerStats", containerId, false).
+			Return(types.ContainerStats{Body: ioutil.NopCloser(bytes.NewReader(b))}, nil)
+		mockClient.On("ContainerInspect", containerId).Return(details, nil)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 7)
+		assert.Equal(t, "ThrottledPeriods", data[2].Name)
+		assert.Equal(t, "ThrottledTime", data[3].Name)
+		assert.Equal(t, "ThrottledPercentage", data[4].Name)
+		assert.Equal(t, 10.0, data[4].Value)
+		assert.Equal(t, "CPUQuotaCores", data[5].Name)
+		assert.Equal(t, 0.25, data[5].Value)
+		assert.Equal(t, makeContainerDimensions(containerId), data[5].Dimensions)
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("cpu throttling since the previous collection", func(t *testing.T) {
+		makeThrottledStats := func(periods, throttledPeriods uint64) types.ContainerStats {
+			stats := types.StatsJSON{}
+			stats.CPUStats.ThrottlingData = types.ThrottlingData{Periods: periods, ThrottledPeriods: throttledPeriods}
+			// the previous sample of the daemon must not be used for the throttling over the collection interval
+			stats.PreCPUStats.ThrottlingData = types.ThrottlingData{Periods: periods - 1}
+			b, _ := json.Marshal(stats)
+			return types.ContainerStats{Body: ioutil.NopCloser(bytes.NewReader(b))}
+		}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{makeContainer("c")}, nil)
+		mockClient.On("ContainerStats", "c", false).Return(makeThrottledStats(100, 10), nil).Once()
+		mockClient.On("ContainerStats", "c", false).Return(makeThrottledStats(200, 60), nil).Once()
+		mockClient.On("ContainerInspect", "c").Return(makeContainerDetails(""), nil)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}}
+		data, err := d.Gather()
+		assert.NoError(t, err)
+		assert.Equal(t, "ThrottledPercentage", data[4].Name)
+		assert.Equal(t, 10.0, data[4].Value)
+
+		data, err = d.Gather()
+		assert.NoError(t, err)
+		assert.Equal(t, "ThrottledPercentage", data[4].Name)
+		assert.Equal(t, 50.0, data[4].Value)
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("stats aggregated by label", func(t *testing.T) {
+		containers := []types.Container{
+			makeServiceContainer("c1", "web"),
+			makeServiceContainer("c2", "web"),
+			makeServiceContainer("c3", "worker"),
+		}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).Return(containers, nil)
+		mockClient.On("ContainerStats", "c1", false).Return(makeContainerStats(2, 100, 200, 200), nil)
+		mockClient.On("ContainerStats", "c2", false).Return(makeContainerStats(2, 50, 200, 400), nil)
+		mockClient.On("ContainerStats", "c3", false).Return(makeContainerStats(2, 20, 200, 100), nil)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}, AggregateLabel: "service"}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 13)
+
+		webDimensions := []Dimension{{Name: "Service", Value: "web"}}
+		expectedWeb := map[string]float64{
+			"CPUUtilizationSum":        150.0,
+			"CPUUtilizationAverage":    75.0,
+			"CPUUtilizationMaximum":    100.0,
+			"MemoryUtilizationSum":     600.0,
+			"MemoryUtilizationAverage": 300.0,
+			"MemoryUtilizationMaximum": 400.0,
+		}
+		for _, p := range data[:6] {
+			assert.Equal(t, webDimensions, p.Dimensions)
+			assert.Equal(t, expectedWeb[p.Name], p.Value, p.Name)
+		}
+
+		workerDimensions := []Dimension{{Name: "Service", Value: "worker"}}
+		for _, p := range data[6:12] {
+			assert.Equal(t, workerDimensions, p.Dimensions)
+		}
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("throttling and pids aggregated by label", func(t *testing.T) {
+		makeLimitedStats := func(throttledPeriods, pids uint64) types.ContainerStats {
+			stats := types.StatsJSON{}
+			stats.CPUStats.ThrottlingData = types.ThrottlingData{Periods: 100, ThrottledPeriods: throttledPeriods}
+			stats.PidsStats = types.PidsStats{Current: pids, Limit: 100}
+			b, _ := json.Marshal(stats)
+			return types.ContainerStats{Body: ioutil.NopCloser(bytes.NewReader(b))}
+		}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).Return([]types.Container{
+			makeServiceContainer("c1", "web"),
+			makeServiceContainer("c2", "web"),
+		}, nil)
+		mockClient.On("ContainerStats", "c1", false).Return(makeLimitedStats(10, 20), nil)
+		mockClient.On("ContainerStats", "c2", false).Return(makeLimitedStats(30, 40), nil)
+		mockClient.On("ContainerInspect", mock.Anything).Return(makeContainerDetails(""), nil)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}, AggregateLabel: "service"}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		points := map[string]float64{}
+		for _, p := range data {
+			points[p.Name] = p.Value
+		}
+		assert.Equal(t, 60.0, points["PidsCurrentSum"])
+		assert.Equal(t, 30.0, points["PidsUtilizationAverage"])
+		assert.Equal(t, 40.0, points["PidsUtilizationMaximum"])
+		assert.Equal(t, 20.0, points["ThrottledPercentageAverage"])
+		assert.Equal(t, 30.0, points["ThrottledPercentageMaximum"])
+		assert.NotContains(t, points, "ThrottledPeriods")
+		assert.NotContains(t, points, "PidsLimit")
+	})
+
+	t.Run("stats from many containers in parallel", func(t *testing.T) {
+		containers := make([]types.Container, 20)
+		mockClient := new(DockerMockClient)
+		for i := range containers {
+			containers[i] = makeContainer(strconv.Itoa(i))
+			mockClient.On("ContainerStats", strconv.Itoa(i), false).
+				Return(makeContainerStats(1, uint64(i), 100, uint64(i)), nil).
+				After(10 * time.Millisecond)
+		}
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).Return(containers, nil)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}, Concurrency: 10}
+		start := time.Now()
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.True(t, time.Since(start) < 150*time.Millisecond)
+		assert.Len(t, data, 41)
+		for i := range containers {
+			assert.Equal(t, makeContainerDimensions(strconv.Itoa(i)), data[2*i].Dimensions)
+			assert.Equal(t, float64(i), data[2*i+1].Value)
+		}
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("stats timing out", func(t *testing.T) {
+		containerId1, containerId2 := "c1", "c2"
+		containers := []types.Container{makeContainer(containerId1), makeContainer(containerId2)}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).Return(containers, nil)
+		mockClient.On("ContainerStats", containerId1, false).Return(makeContainerStats(2, 100, 200, 200), nil)
+		mockClient.On("ContainerStats", containerId2, false).
+			Return(makeContainerStats(2, 50, 200, 400), nil).
+			After(50 * time.Millisecond)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}, Timeout: 10 * time.Millisecond}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 3)
+		assert.Equal(t, makeContainerDimensions(containerId1), data[0].Dimensions)
+		assert.Equal(t, makeContainerDimensions(containerId1), data[1].Dimensions)
+
+		assert.Equal(t, "ContainerStatsTimeouts", data[2].Name)
+		assert.Equal(t, string(UnitCount), string(data[2].Unit))
+		assert.Equal(t, 1.0, data[2].Value)
+
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("container list bounded by the deadline", func(t *testing.T) {
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).
+			Return([]types.Container{makeContainer("c1")}, nil).
+			After(50 * time.Millisecond)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}, Deadline: 20 * time.Millisecond}
+		data, err := d.Gather()
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "context deadline exceeded")
+		assert.Len(t, data, 0)
+	})
+
+	t.Run("stats reaching the deadline", func(t *testing.T) {
+		containers := []types.Container{makeContainer("c1"), makeContainer("c2"), makeContainer("c3")}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).Return(containers, nil)
+		mockClient.On("ContainerStats", "c1", false).
+			Return(makeContainerStats(2, 100, 200, 200), nil).
+			After(50 * time.Millisecond)
+
+		d := DockerStat{dockerMetric: dockerMetric{client: mockClient}, Concurrency: 1, Deadline: 20 * time.Millisecond}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 1)
+		assert.Equal(t, "ContainerStatsTimeouts", data[0].Name)
+		assert.Equal(t, 3.0, data[0].Value)
+	})
+
 	t.Run("stats returns error", func(t *testing.T) {
 		containerId := "c"
 		containers := []types.Container{makeContainer(containerId)}
@@ -211,7 +677,9 @@ func TestDockerStat_Gather(t *testing.T) {
 		data, err := d.Gather()
 
 		assert.NoError(t, err)
-		assert.Len(t, data, 0)
+		assert.Len(t, data, 1)
+		assert.Equal(t, "ContainerStatsTimeouts", data[0].Name)
+		assert.Equal(t, 0.0, data[0].Value)
 
 		mockClient.AssertExpectations(t)
 	})
@@ -231,7 +699,8 @@ func TestDockerStat_Gather(t *testing.T) {
 		data, err := d.Gather()
 
 		assert.NoError(t, err)
-		assert.Len(t, data, 0)
+		assert.Len(t, data, 1)
+		assert.Equal(t, "ContainerStatsTimeouts", data[0].Name)
 
 		mockClient.AssertExpectations(t)
 	})
@@ -300,6 +769,44 @@ func TestDockerHealth_Gather(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("health aggregated by label", func(t *testing.T) {
+		containers := []types.Container{
+			makeServiceContainer("c1", "web"),
+			makeServiceContainer("c2", "web"),
+			makeServiceContainer("c3", "worker"),
+		}
+
+		mockClient := new(DockerMockClient)
+		mockClient.On("ContainerList", types.ContainerListOptions{All: false}).Return(containers, nil)
+		mockClient.On("ContainerInspect", "c1").Return(makeContainerDetails("Healthy"), nil)
+		mockClient.On("ContainerInspect", "c2").Return(makeContainerDetails("Unhealthy"), nil)
+		mockClient.On("ContainerInspect", "c3").Return(makeContainerDetails("Healthy"), nil)
+
+		d := DockerHealth{dockerMetric: dockerMetric{client: mockClient}, AggregateLabel: "service"}
+		data, err := d.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 4)
+
+		webDimensions := []Dimension{{Name: "Service", Value: "web"}}
+		assert.Equal(t, "HealthyReplicas", data[0].Name)
+		assert.Equal(t, 1.0, data[0].Value)
+		assert.Equal(t, webDimensions, data[0].Dimensions)
+		assert.Equal(t, "TotalReplicas", data[1].Name)
+		assert.Equal(t, 2.0, data[1].Value)
+		assert.Equal(t, webDimensions, data[1].Dimensions)
+
+		workerDimensions := []Dimension{{Name: "Service", Value: "worker"}}
+		assert.Equal(t, "HealthyReplicas", data[2].Name)
+		assert.Equal(t, 1.0, data[2].Value)
+		assert.Equal(t, workerDimensions, data[2].Dimensions)
+		assert.Equal(t, "TotalReplicas", data[3].Name)
+		assert.Equal(t, 1.0, data[3].Value)
+		assert.Equal(t, workerDimensions, data[3].Dimensions)
+
+		mockClient.AssertExpectations(t)
+	})
+
 	t.Run("error from container inspect", func(t *testing.T) {
 		containerId1 := "c1"
 		containers := []types.Container{makeContainer(containerId1)}
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultExecTimeout = 10 * time.Second
+
+// commandResult is the outcome of running a command
+type commandResult struct {
+	stdout   []byte
+	stderr   []byte
+	exitCode int
+}
+
+// runCommand runs the command with sh and waits for it to complete or for the timeout to expire.
+// It returns error if the command cannot be started or does not complete in time. A command
+// completing with a non zero exit code is not considered an error.
+func runCommand(command string, timeout time.Duration) (commandResult, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// the command runs in its own process group so that on timeout the processes it started,
+	// which could keep its output open, are killed with it
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return commandResult{}, errors.Wrapf(err, "failed to run command [%s]", command)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-timer.C:
+		killProcessGroup(cmd)
+		<-done
+		return commandResult{}, errors.Errorf("command [%s] timed out after %s", command, timeout)
+	}
+
+	result := commandResult{stdout: stdout.Bytes(), stderr: stderr.Bytes()}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		result.exitCode = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+		return result, nil
+	}
+	if err != nil {
+		return commandResult{}, errors.Wrapf(err, "failed to run command [%s]", command)
+	}
+	return result, nil
+}
+
+// execJSONPoint is the JSON representation of a data point printed by a command
+type execJSONPoint struct {
+	Name       string            `json:"name"`
+	Value      *float64          `json:"value"`
+	Unit       string            `json:"unit"`
+	Dimensions map[string]string `json:"dimensions"`
+}
+
+// parseExecLine parses a data point from a line of the form
+//
+//	name value [unit] [dimension=value ...]
+//
+// or from a JSON object of the form
+//
+//	{"name": "name", "value": 1.0, "unit": "Count", "dimensions": {"dimension": "value"}}
+//
+// The unit is one of the CloudWatch units, ignoring case, and it is None if not given.
+func parseExecLine(line string) (Point, error) {
+	if strings.HasPrefix(line, "{") {
+		var p execJSONPoint
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			return Point{}, errors.Wrap(err, "invalid JSON data point")
+		}
+		if p.Name == "" || p.Value == nil {
+			return Point{}, errors.New("JSON data point requires a name and a value")
+		}
+
+		unit := UnitNone
+		if p.Unit != "" {
+			var err error
+			if unit, err = ParseUnit(p.Unit); err != nil {
+				return Point{}, err
+			}
+		}
+		dimensions, err := MapToDimensions(p.Dimensions)
+		if err != nil {
+			return Point{}, err
+		}
+		return NewDataPoint(p.Name, *p.Value, unit, dimensions...), nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Point{}, errors.New("data point requires a name and a value")
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Point{}, errors.Wrapf(err, "invalid value [%s]", fields[1])
+	}
+
+	unit, rest := UnitNone, fields[2:]
+	if len(rest) > 0 && !strings.Contains(rest[0], "=") {
+		if unit, err = ParseUnit(rest[0]); err != nil {
+			return Point{}, err
+		}
+		rest = rest[1:]
+	}
+
+	dimensions := make([]Dimension, 0, len(rest))
+	for _, field := range rest {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return Point{}, errors.Errorf("invalid dimension [%s]", field)
+		}
+		d, err := NewDimension(kv[0], kv[1])
+		if err != nil {
+			return Point{}, err
+		}
+		dimensions = append(dimensions, d)
+	}
+
+	return NewDataPoint(fields[0], value, unit, dimensions...), nil
+}
+
+// parseExecOutput parses the data points printed by a command, one per line, returning the number of
+// malformed lines. Empty lines and lines starting with # are ignored while malformed lines are logged and skipped.
+func parseExecOutput(command string, output []byte) (Data, int) {
+	data := Data{}
+	malformed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parseExecLine(line)
+		if err != nil {
+			log.Warnf("skipping malformed line %d of the output of command [%s]: %s", lineNumber, command, err)
+			malformed++
+			continue
+		}
+		data = append(data, &p)
+	}
+	return data, malformed
+}
+
+// Exec collects the data points printed by a list of commands, run with sh
+type Exec struct {
+	Commands       []string
+	Timeout        time.Duration
+	ReportExitCode bool
+}
+
+// Name of the Exec metric
+func (e Exec) Name() string {
+	return "exec"
+}
+
+func (e Exec) timeout() time.Duration {
+	if e.Timeout > 0 {
+		return e.Timeout
+	}
+	return defaultExecTimeout
+}
+
+// run the command and return the data points it printed together with the data points reporting
+// whether the command failed and the number of malformed lines it printed
+func (e Exec) run(command string) Data {
+	commandDim, _ := NewDimension("Command", command)
+
+	data := Data{}
+	failed, malformed, exitCode := 0, 0, -1
+	result, err := runCommand(command, e.timeout())
+	if err != nil {
+		log.Warn(err)
+		failed = 1
+	} else {
+		if result.exitCode != 0 {
+			log.Warnf("command [%s] exited with code %d: %s", command, result.exitCode, strings.TrimSpace(string(result.stderr)))
+		}
+		data, malformed = parseExecOutput(command, result.stdout)
+		exitCode = result.exitCode
+	}
+
+	failedPoint := NewDataPoint("CommandFailed", float64(failed), UnitCount, commandDim)
+	malformedPoint := NewDataPoint("MalformedLines", float64(malformed), UnitCount, commandDim)
+	data = append(data, &failedPoint, &malformedPoint)
+	if e.ReportExitCode {
+		exitCodePoint := NewDataPoint("ExitCode", float64(exitCode), UnitNone, commandDim)
+		data = append(data, &exitCodePoint)
+	}
+	return data
+}
+
+// Gather runs all the commands in parallel and returns the data points printed on their standard output,
+// one per line, in one of the formats accepted by parseExecLine, and the following data points for every
+// command, with the Command dimension
+// - CommandFailed (count) 1 if the command failed to start or timed out, 0 otherwise
+// - MalformedLines (count) number of lines of the output that are not valid data points
+// - ExitCode (none) exit code of the command, -1 if it failed to start or timed out, if ReportExitCode is set
+// The data points printed by commands exiting with a non zero code are still returned.
+func (e Exec) Gather() (Data, error) {
+	log.Debug("gathering exec commands")
+
+	results := make([]Data, len(e.Commands))
+	var wg sync.WaitGroup
+	for i, command := range e.Commands {
+		wg.Add(1)
+		go func(i int, command string) {
+			defer wg.Done()
+			results[i] = e.run(command)
+		}(i, command)
+	}
+	wg.Wait()
+
+	data := Data{}
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommand(t *testing.T) {
+	t.Run("successful command", func(t *testing.T) {
+		result, err := runCommand("echo out; echo err >&2", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, "out\n", string(result.stdout))
+		assert.Equal(t, "err\n", string(result.stderr))
+		assert.Equal(t, 0, result.exitCode)
+	})
+
+	t.Run("non zero exit code", func(t *testing.T) {
+		result, err := runCommand("echo out; exit 3", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, "out\n", string(result.stdout))
+		assert.Equal(t, 3, result.exitCode)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := runCommand("sleep 10", 50*time.Millisecond)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out")
+		assert.True(t, time.Since(start) < 5*time.Second)
+	})
+
+	t.Run("timeout with background processes", func(t *testing.T) {
+		start := time.Now()
+		// the background process keeps the output open after the command exits
+		_, err := runCommand("sleep 10 & echo started", 50*time.Millisecond)
+		assert.Error(t, err)
+		assert.True(t, time.Since(start) < 5*time.Second)
+	})
+}
+
+func TestParseExecLine(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected Point
+	}{
+		{line: "QueueLength 12", expected: Point{Name: "QueueLength", Value: 12, Unit: UnitNone, Dimensions: []Dimension{}}},
+		{line: "QueueLength 12 count", expected: Point{Name: "QueueLength", Value: 12, Unit: UnitCount, Dimensions: []Dimension{}}},
+		{
+			line:     "Latency 1.5 Milliseconds queue=jobs env=prod",
+			expected: Point{Name: "Latency", Value: 1.5, Unit: UnitMilliseconds, Dimensions: []Dimension{{"queue", "jobs"}, {"env", "prod"}}},
+		},
+		{
+			line:     "Latency -2e3 queue=jobs",
+			expected: Point{Name: "Latency", Value: -2000, Unit: UnitNone, Dimensions: []Dimension{{"queue", "jobs"}}},
+		},
+		{
+			line:     `{"name": "Backlog", "value": 7, "unit": "Count", "dimensions": {"queue": "jobs"}}`,
+			expected: Point{Name: "Backlog", Value: 7, Unit: UnitCount, Dimensions: []Dimension{{"queue", "jobs"}}},
+		},
+		{
+			line:     `{"name": "Backlog", "value": 0}`,
+			expected: Point{Name: "Backlog", Value: 0, Unit: UnitNone, Dimensions: []Dimension{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			p, err := parseExecLine(tc.line)
+			assert.NoError(t, err)
+			p.Timestamp = tc.expected.Timestamp
+			assert.Equal(t, tc.expected, p)
+		})
+	}
+
+	invalid := []string{
+		"QueueLength",
+		"QueueLength twelve",
+		"QueueLength 12 Hours",
+		"QueueLength 12 Count queue",
+		"QueueLength 12 Count =jobs",
+		`{"name": "Backlog"}`,
+		`{"value": 1}`,
+		`{"name": "Backlog", "value": 1, "unit": "Hours"}`,
+		`{"name": "Backlog", "value": "1"}`,
+	}
+	for _, line := range invalid {
+		t.Run(line, func(t *testing.T) {
+			_, err := parseExecLine(line)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseExecOutput(t *testing.T) {
+	output := "# a comment\n\nQueueLength 12 Count\nmalformed\n  Latency 1.5 Milliseconds  \n"
+	data, malformed := parseExecOutput("command", []byte(output))
+
+	assert.Equal(t, 1, malformed)
+	assert.Len(t, data, 2)
+	assert.Equal(t, "QueueLength", data[0].Name)
+	assert.Equal(t, "Latency", data[1].Name)
+}
+
+func TestExec_Name(t *testing.T) {
+	e := Exec{}
+	assert.Equal(t, "exec", e.Name())
+}
+
+func TestExec_Gather(t *testing.T) {
+	t.Run("data points from all commands", func(t *testing.T) {
+		e := Exec{Commands: []string{"echo 'QueueLength 12 Count'", `echo '{"name": "Backlog", "value": 3}'; exit 1`}}
+		data, err := e.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 2+4)
+		assert.Equal(t, 12.0, findPoint(data, "QueueLength").Value)
+		assert.Equal(t, 3.0, findPoint(data, "Backlog").Value)
+	})
+
+	t.Run("failures and malformed lines", func(t *testing.T) {
+		e := Exec{Commands: []string{"echo 'QueueLength 12'; echo malformed", "sleep 10"}, Timeout: 50 * time.Millisecond}
+		data, err := e.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 1+4)
+		assert.Equal(t, 0.0, findPoint(data, "CommandFailed", Dimension{"Command", "echo 'QueueLength 12'; echo malformed"}).Value)
+		assert.Equal(t, 1.0, findPoint(data, "MalformedLines", Dimension{"Command", "echo 'QueueLength 12'; echo malformed"}).Value)
+		assert.Equal(t, 1.0, findPoint(data, "CommandFailed", Dimension{"Command", "sleep 10"}).Value)
+		assert.Equal(t, UnitCount, findPoint(data, "CommandFailed", Dimension{"Command", "sleep 10"}).Unit)
+		assert.Equal(t, 0.0, findPoint(data, "MalformedLines", Dimension{"Command", "sleep 10"}).Value)
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		e := Exec{Commands: []string{"echo 'QueueLength 12'", "exit 2", "sleep 10"}, Timeout: 50 * time.Millisecond, ReportExitCode: true}
+		data, err := e.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 1+3*3)
+		assert.Equal(t, 0.0, findPoint(data, "ExitCode", Dimension{"Command", "echo 'QueueLength 12'"}).Value)
+		assert.Equal(t, 2.0, findPoint(data, "ExitCode", Dimension{"Command", "exit 2"}).Value)
+		assert.Equal(t, -1.0, findPoint(data, "ExitCode", Dimension{"Command", "sleep 10"}).Value)
+	})
+}
@@ -0,0 +1,11 @@
+package metrics
+
+import "os/exec"
+
+// setProcessGroup does nothing since process groups are not supported
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the started command
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHTTPCheckTimeout = 5 * time.Second
+	maxHTTPCheckBodySize    = 1 << 20
+)
+
+// HTTPTarget is an endpoint checked by the HTTPCheck metric.
+// The response is expected to have one of the ExpectedStatus codes, or a code lower than 400 if none
+// is given, and a body matching BodyPattern, if set.
+type HTTPTarget struct {
+	URL                string
+	Method             string
+	ExpectedStatus     []int
+	BodyPattern        *regexp.Regexp
+	Timeout            time.Duration
+	InsecureSkipVerify bool
+}
+
+// ParseHTTPTarget parses a target from its specification of the form
+// url[;method=GET][;status=200|204][;body=regex][;timeout=5s][;insecure=true]
+// or returns an error if the specification is not valid
+func ParseHTTPTarget(spec string) (HTTPTarget, error) {
+	parts := strings.Split(spec, ";")
+	target := HTTPTarget{URL: strings.TrimSpace(parts[0]), Method: http.MethodGet, Timeout: defaultHTTPCheckTimeout}
+	if !strings.HasPrefix(target.URL, "http://") && !strings.HasPrefix(target.URL, "https://") {
+		return HTTPTarget{}, errors.Errorf("invalid http check target [%s]: url must start with http:// or https://", spec)
+	}
+
+	for _, option := range parts[1:] {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return HTTPTarget{}, errors.Errorf("invalid http check target [%s]: option [%s] must be of the form key=value", spec, option)
+		}
+
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "method":
+			target.Method = strings.ToUpper(value)
+		case "status":
+			for _, s := range strings.Split(value, "|") {
+				status, err := strconv.Atoi(s)
+				if err != nil {
+					return HTTPTarget{}, errors.Wrapf(err, "invalid http check target [%s]: invalid status [%s]", spec, s)
+				}
+				target.ExpectedStatus = append(target.ExpectedStatus, status)
+			}
+		case "body":
+			pattern, err := regexp.Compile(value)
+			if err != nil {
+				return HTTPTarget{}, errors.Wrapf(err, "invalid http check target [%s]: invalid body pattern", spec)
+			}
+			target.BodyPattern = pattern
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return HTTPTarget{}, errors.Wrapf(err, "invalid http check target [%s]: invalid timeout", spec)
+			}
+			target.Timeout = timeout
+		case "insecure":
+			insecure, err := strconv.ParseBool(value)
+			if err != nil {
+				return HTTPTarget{}, errors.Wrapf(err, "invalid http check target [%s]: invalid insecure flag", spec)
+			}
+			target.InsecureSkipVerify = insecure
+		default:
+			return HTTPTarget{}, errors.Errorf("invalid http check target [%s]: unknown option [%s]", spec, key)
+		}
+	}
+
+	return target, nil
+}
+
+func (t HTTPTarget) expectedStatus(status int) bool {
+	if len(t.ExpectedStatus) == 0 {
+		return status < http.StatusBadRequest
+	}
+	for _, s := range t.ExpectedStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// check performs the request to the target and returns the data points describing its outcome
+func (t HTTPTarget) check() Data {
+	targetDim, _ := NewDimension("Target", t.URL)
+
+	up, data := t.probe(targetDim)
+	upPoint := NewDataPoint("Up", up, UnitCount, targetDim)
+	return append(Data{&upPoint}, data...)
+}
+
+// probe performs the request to the target and returns 1 if the target is up, 0 otherwise,
+// together with the data points describing the response, if any
+func (t HTTPTarget) probe(targetDim Dimension) (float64, Data) {
+	request, err := http.NewRequest(t.Method, t.URL, nil)
+	if err != nil {
+		log.Warnf("failed to create request for http check target [%s]: %s", t.URL, err)
+		return 0, Data{}
+	}
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}}
+	defer transport.CloseIdleConnections()
+	client := http.Client{
+		Transport: transport,
+		Timeout:   t.Timeout,
+		// redirects are not followed so that the status of the target itself is checked
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	start := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		log.Warnf("http check target [%s] failed: %s", t.URL, err)
+		return 0, Data{}
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(&io.LimitedReader{R: response.Body, N: maxHTTPCheckBodySize})
+	responseTime := time.Since(start)
+
+	responseTimePoint := NewDataPoint("ResponseTime", float64(responseTime)/float64(time.Millisecond), UnitMilliseconds, targetDim)
+	statusCodePoint := NewDataPoint("StatusCode", float64(response.StatusCode), UnitNone, targetDim)
+	data := Data{&responseTimePoint, &statusCodePoint}
+
+	if response.TLS != nil && len(response.TLS.PeerCertificates) > 0 {
+		expiry := time.Until(response.TLS.PeerCertificates[0].NotAfter).Hours() / 24
+		expiryPoint := NewDataPoint("CertificateExpiryDays", expiry, UnitNone, targetDim)
+		data = append(data, &expiryPoint)
+	}
+
+	switch {
+	case err != nil:
+		log.Warnf("failed to read the response of http check target [%s]: %s", t.URL, err)
+	case !t.expectedStatus(response.StatusCode):
+		log.Warnf("http check target [%s] returned unexpected status [%d]", t.URL, response.StatusCode)
+	case t.BodyPattern != nil && !t.BodyPattern.Match(body):
+		log.Warnf("http check target [%s] returned a body not matching [%s]", t.URL, t.BodyPattern)
+	default:
+		return 1, data
+	}
+	return 0, data
+}
+
+// HTTPCheck checks the health of a list of HTTP endpoints
+type HTTPCheck struct {
+	Targets []HTTPTarget
+}
+
+// Name of the HTTPCheck metric
+func (h HTTPCheck) Name() string {
+	return "http-check"
+}
+
+// Gather checks all the targets in parallel and returns the following data points for every target,
+// with the Target dimension
+// - Up (count) 1 if the target returned the expected status and body, 0 otherwise
+// - ResponseTime (milliseconds) if the target responded
+// - StatusCode (none) if the target responded
+// - CertificateExpiryDays (none) days until the certificate of the target expires, for https targets
+func (h HTTPCheck) Gather() (Data, error) {
+	log.Debug("gathering http checks")
+
+	results := make([]Data, len(h.Targets))
+	var wg sync.WaitGroup
+	for i, target := range h.Targets {
+		wg.Add(1)
+		go func(i int, target HTTPTarget) {
+			defer wg.Done()
+			results[i] = target.check()
+		}(i, target)
+	}
+	wg.Wait()
+
+	data := Data{}
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHTTPTarget(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		target, err := ParseHTTPTarget("http://localhost:8080/health")
+		assert.NoError(t, err)
+		assert.Equal(t, HTTPTarget{URL: "http://localhost:8080/health", Method: "GET", Timeout: 5 * time.Second}, target)
+	})
+
+	t.Run("all options", func(t *testing.T) {
+		target, err := ParseHTTPTarget("https://example.com;method=head;status=200|204;body=^ok$;timeout=2s;insecure=true")
+		assert.NoError(t, err)
+		assert.Equal(t, HTTPTarget{
+			URL:                "https://example.com",
+			Method:             "HEAD",
+			ExpectedStatus:     []int{200, 204},
+			BodyPattern:        regexp.MustCompile("^ok$"),
+			Timeout:            2 * time.Second,
+			InsecureSkipVerify: true,
+		}, target)
+	})
+
+	invalid := []string{
+		"localhost:8080",
+		"http://localhost;status=ok",
+		"http://localhost;body=(",
+		"http://localhost;timeout=5",
+		"http://localhost;insecure=maybe",
+		"http://localhost;method",
+		"http://localhost;foo=bar",
+	}
+	for _, spec := range invalid {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseHTTPTarget(spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHTTPCheck_Name(t *testing.T) {
+	h := HTTPCheck{}
+	assert.Equal(t, "http-check", h.Name())
+}
+
+func TestHTTPCheck_Gather(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte(`{"status": "ok"}`))
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/redirect":
+			http.Redirect(w, r, "/health", http.StatusFound)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	testCases := []struct {
+		name     string
+		spec     string
+		up       float64
+		status   float64
+		response bool
+	}{
+		{name: "healthy", spec: server.URL + "/health", up: 1, status: 200, response: true},
+		{name: "matching body", spec: server.URL + "/health;body=\"status\": \"ok\"", up: 1, status: 200, response: true},
+		{name: "not matching body", spec: server.URL + "/health;body=failed", up: 0, status: 200, response: true},
+		{name: "expected status", spec: server.URL + "/created;status=200|201", up: 1, status: 201, response: true},
+		{name: "unexpected status", spec: server.URL + "/created;status=200", up: 0, status: 201, response: true},
+		{name: "server error", spec: server.URL + "/error", up: 0, status: 500, response: true},
+		{name: "redirect is not followed", spec: server.URL + "/redirect;status=302", up: 1, status: 302, response: true},
+		{name: "timeout", spec: server.URL + "/slow;timeout=10ms", up: 0, response: false},
+		{name: "unreachable", spec: "http://127.0.0.1:1/health", up: 0, response: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := ParseHTTPTarget(tc.spec)
+			assert.NoError(t, err)
+
+			data, err := HTTPCheck{Targets: []HTTPTarget{target}}.Gather()
+			assert.NoError(t, err)
+
+			targetDim := Dimension{Name: "Target", Value: target.URL}
+			assert.Equal(t, tc.up, findPoint(data, "Up", targetDim).Value)
+			if !tc.response {
+				assert.Len(t, data, 1)
+				return
+			}
+			assert.Len(t, data, 3)
+			assert.Equal(t, tc.status, findPoint(data, "StatusCode", targetDim).Value)
+			assert.True(t, findPoint(data, "ResponseTime", targetDim).Value >= 0)
+			assert.Equal(t, UnitMilliseconds, findPoint(data, "ResponseTime", targetDim).Unit)
+		})
+	}
+
+	t.Run("multiple targets", func(t *testing.T) {
+		healthy, _ := ParseHTTPTarget(server.URL + "/health")
+		failing, _ := ParseHTTPTarget(server.URL + "/error")
+
+		data, err := HTTPCheck{Targets: []HTTPTarget{healthy, failing}}.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 6)
+		assert.Equal(t, 1.0, findPoint(data, "Up", Dimension{Name: "Target", Value: healthy.URL}).Value)
+		assert.Equal(t, 0.0, findPoint(data, "Up", Dimension{Name: "Target", Value: failing.URL}).Value)
+	})
+}
+
+func TestHTTPCheck_GatherTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	t.Run("certificate expiry", func(t *testing.T) {
+		target, _ := ParseHTTPTarget(server.URL + ";insecure=true")
+		data, err := HTTPCheck{Targets: []HTTPTarget{target}}.Gather()
+		assert.NoError(t, err)
+
+		targetDim := Dimension{Name: "Target", Value: server.URL}
+		assert.Equal(t, 1.0, findPoint(data, "Up", targetDim).Value)
+		expected := time.Until(server.Certificate().NotAfter).Hours() / 24
+		assert.InDelta(t, expected, findPoint(data, "CertificateExpiryDays", targetDim).Value, 0.01)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		target, _ := ParseHTTPTarget(server.URL)
+		data, err := HTTPCheck{Targets: []HTTPTarget{target}}.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 1)
+		assert.Equal(t, 0.0, data[0].Value)
+	})
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultKubeletURL     = "https://localhost:10250"
+	defaultKubeletTimeout = 10 * time.Second
+)
+
+// kubeletSummary is the subset of the kubelet summary API response used by the Kubelet metric
+// via https://github.com/kubernetes/kubernetes/blob/v1.11.0/pkg/kubelet/apis/stats/v1alpha1/types.go
+type kubeletSummary struct {
+	Pods []kubeletPodStats `json:"pods"`
+}
+
+type kubeletPodStats struct {
+	PodRef struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"podRef"`
+	Containers       []kubeletContainerStats `json:"containers"`
+	CPU              *kubeletCPUStats        `json:"cpu"`
+	Memory           *kubeletMemoryStats     `json:"memory"`
+	Network          *kubeletNetworkStats    `json:"network"`
+	EphemeralStorage *kubeletFsStats         `json:"ephemeral-storage"`
+}
+
+type kubeletContainerStats struct {
+	Name   string              `json:"name"`
+	CPU    *kubeletCPUStats    `json:"cpu"`
+	Memory *kubeletMemoryStats `json:"memory"`
+	Rootfs *kubeletFsStats     `json:"rootfs"`
+	Logs   *kubeletFsStats     `json:"logs"`
+}
+
+type kubeletCPUStats struct {
+	UsageNanoCores *uint64 `json:"usageNanoCores"`
+}
+
+type kubeletMemoryStats struct {
+	WorkingSetBytes *uint64 `json:"workingSetBytes"`
+}
+
+type kubeletNetworkStats struct {
+	RxBytes *uint64 `json:"rxBytes"`
+	TxBytes *uint64 `json:"txBytes"`
+}
+
+type kubeletFsStats struct {
+	UsedBytes *uint64 `json:"usedBytes"`
+}
+
+// Kubelet collects pod and container statistics from the summary API of the kubelet running at URL.
+// Requests are authenticated with the bearer token read from TokenFile, if set, which usually is the
+// token of the service account of the pod running cwmonitor.
+type Kubelet struct {
+	URL                string
+	TokenFile          string
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+// Name of the Kubelet metric
+func (k Kubelet) Name() string {
+	return "kubelet"
+}
+
+func (k Kubelet) url() string {
+	if k.URL != "" {
+		return strings.TrimRight(k.URL, "/")
+	}
+	return defaultKubeletURL
+}
+
+func (k Kubelet) timeout() time.Duration {
+	if k.Timeout > 0 {
+		return k.Timeout
+	}
+	return defaultKubeletTimeout
+}
+
+func (k Kubelet) getSummary() (kubeletSummary, error) {
+	request, err := http.NewRequest(http.MethodGet, k.url()+"/stats/summary", nil)
+	if err != nil {
+		return kubeletSummary{}, errors.Wrap(err, "failed to create kubelet summary request")
+	}
+
+	// the token is read on every request since service account tokens can be rotated
+	if k.TokenFile != "" {
+		token, err := ioutil.ReadFile(k.TokenFile)
+		if err != nil {
+			return kubeletSummary{}, errors.Wrapf(err, "failed to read kubelet token from [%s]", k.TokenFile)
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: k.InsecureSkipVerify}}
+	defer transport.CloseIdleConnections()
+	client := http.Client{Transport: transport, Timeout: k.timeout()}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return kubeletSummary{}, errors.Wrap(err, "failed to fetch kubelet summary")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return kubeletSummary{}, errors.Errorf("failed to fetch kubelet summary: unexpected status [%s]", response.Status)
+	}
+
+	var summary kubeletSummary
+	if err := json.NewDecoder(response.Body).Decode(&summary); err != nil {
+		return kubeletSummary{}, errors.Wrap(err, "failed to decode kubelet summary")
+	}
+	return summary, nil
+}
+
+// appendIfPresent appends a data point to data if the value reported by the kubelet is present
+func appendIfPresent(data Data, name string, value *uint64, scale float64, unit Unit, dimensions ...Dimension) Data {
+	if value == nil {
+		return data
+	}
+	p := NewDataPoint(name, float64(*value)*scale, unit, dimensions...)
+	return append(data, &p)
+}
+
+// appendCumulativeIfPresent appends a cumulative data point for the value if present
+func appendCumulativeIfPresent(data Data, name string, value *uint64, unit Unit, dimensions ...Dimension) Data {
+	if value == nil {
+		return data
+	}
+	p := NewCumulativeDataPoint(name, float64(*value), unit, dimensions...)
+	return append(data, &p)
+}
+
+// Gather pod and container statistics from the kubelet or error if the summary cannot be fetched.
+// It will return the following data points for every pod, with the Namespace and Pod dimensions
+// - CPUUtilization (percent) of a single CPU
+// - MemoryUtilization (bytes) working set of the pod
+// - NetworkRxBytes and NetworkTxBytes (bytes) since the pod started
+// - EphemeralStorageUsed (bytes)
+// and the CPUUtilization, MemoryUtilization and EphemeralStorageUsed data points for every container,
+// with the additional Container dimension. Statistics not reported by the kubelet are skipped.
+func (k Kubelet) Gather() (Data, error) {
+	log.Debug("gathering kubelet stats")
+
+	summary, err := k.getSummary()
+	if err != nil {
+		return Data{}, err
+	}
+
+	data := Data{}
+	for _, pod := range summary.Pods {
+		namespaceDim, _ := NewDimension("Namespace", pod.PodRef.Namespace)
+		podDim, _ := NewDimension("Pod", pod.PodRef.Name)
+		dimensions := []Dimension{namespaceDim, podDim}
+
+		if pod.CPU != nil {
+			data = appendIfPresent(data, "CPUUtilization", pod.CPU.UsageNanoCores, 1e-7, UnitPercent, dimensions...)
+		}
+		if pod.Memory != nil {
+			data = appendIfPresent(data, "MemoryUtilization", pod.Memory.WorkingSetBytes, 1, UnitBytes, dimensions...)
+		}
+		if pod.Network != nil {
+			data = appendCumulativeIfPresent(data, "NetworkRxBytes", pod.Network.RxBytes, UnitBytes, dimensions...)
+			data = appendCumulativeIfPresent(data, "NetworkTxBytes", pod.Network.TxBytes, UnitBytes, dimensions...)
+		}
+		if pod.EphemeralStorage != nil {
+			data = appendIfPresent(data, "EphemeralStorageUsed", pod.EphemeralStorage.UsedBytes, 1, UnitBytes, dimensions...)
+		}
+
+		for _, container := range pod.Containers {
+			containerDim, _ := NewDimension("Container", container.Name)
+			containerDimensions := []Dimension{namespaceDim, podDim, containerDim}
+
+			if container.CPU != nil {
+				data = appendIfPresent(data, "CPUUtilization", container.CPU.UsageNanoCores, 1e-7, UnitPercent, containerDimensions...)
+			}
+			if container.Memory != nil {
+				data = appendIfPresent(data, "MemoryUtilization", container.Memory.WorkingSetBytes, 1, UnitBytes, containerDimensions...)
+			}
+
+			// the ephemeral storage of a container is given by its writable layer and its logs
+			var ephemeralStorage *uint64
+			for _, fs := range []*kubeletFsStats{container.Rootfs, container.Logs} {
+				if fs != nil && fs.UsedBytes != nil {
+					if ephemeralStorage == nil {
+						ephemeralStorage = new(uint64)
+					}
+					*ephemeralStorage += *fs.UsedBytes
+				}
+			}
+			data = appendIfPresent(data, "EphemeralStorageUsed", ephemeralStorage, 1, UnitBytes, containerDimensions...)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newKubeletServer(t *testing.T, token string) *httptest.Server {
+	summary, err := ioutil.ReadFile("testdata/kubelet/summary.json")
+	assert.NoError(t, err)
+
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats/summary" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(summary)
+	}))
+}
+
+func writeToken(t *testing.T, token string) string {
+	dir, err := ioutil.TempDir("", "kubelet")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(token+"\n"), 0600))
+	return path
+}
+
+func TestKubelet_Name(t *testing.T) {
+	k := Kubelet{}
+	assert.Equal(t, "kubelet", k.Name())
+}
+
+func TestKubelet_Gather(t *testing.T) {
+	t.Run("pods and containers", func(t *testing.T) {
+		server := newKubeletServer(t, "a-token")
+		defer server.Close()
+
+		k := Kubelet{URL: server.URL + "/", TokenFile: writeToken(t, "a-token"), InsecureSkipVerify: true}
+		data, err := k.Gather()
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 5+3+2+1)
+
+		podDims := []Dimension{{Name: "Namespace", Value: "shop"}, {Name: "Pod", Value: "web-7d9f8c6b5-x2x9z"}}
+		assert.Equal(t, 30.0, findPoint(data, "CPUUtilization", podDims...).Value)
+		assert.Equal(t, string(UnitPercent), string(findPoint(data, "CPUUtilization", podDims...).Unit))
+		assert.Equal(t, 125829120.0, findPoint(data, "MemoryUtilization", podDims...).Value)
+		assert.Equal(t, 1000.0, findPoint(data, "NetworkRxBytes", podDims...).Value)
+		assert.Equal(t, 2000.0, findPoint(data, "NetworkTxBytes", podDims...).Value)
+		assert.True(t, findPoint(data, "NetworkRxBytes", podDims...).Cumulative)
+		assert.True(t, findPoint(data, "NetworkTxBytes", podDims...).Cumulative)
+		assert.False(t, findPoint(data, "MemoryUtilization", podDims...).Cumulative)
+		assert.Equal(t, 5120.0, findPoint(data, "EphemeralStorageUsed", podDims...).Value)
+
+		webDims := append(podDims, Dimension{Name: "Container", Value: "web"})
+		assert.Equal(t, 25.0, findPoint(data, "CPUUtilization", webDims...).Value)
+		assert.Equal(t, 104857600.0, findPoint(data, "MemoryUtilization", webDims...).Value)
+		assert.Equal(t, 5120.0, findPoint(data, "EphemeralStorageUsed", webDims...).Value)
+
+		sidecarDims := append(podDims, Dimension{Name: "Container", Value: "sidecar"})
+		assert.Equal(t, 5.0, findPoint(data, "CPUUtilization", sidecarDims...).Value)
+		assert.Nil(t, findPoint(data, "EphemeralStorageUsed", sidecarDims...))
+
+		corednsDims := []Dimension{{Name: "Namespace", Value: "kube-system"}, {Name: "Pod", Value: "coredns-5c98db65d4-abcde"}}
+		assert.Equal(t, 10485760.0, findPoint(data, "MemoryUtilization", corednsDims...).Value)
+		assert.Nil(t, findPoint(data, "CPUUtilization", corednsDims...))
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		server := newKubeletServer(t, "a-token")
+		defer server.Close()
+
+		k := Kubelet{URL: server.URL, TokenFile: writeToken(t, "another-token"), InsecureSkipVerify: true}
+		data, err := k.Gather()
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "401")
+		assert.Len(t, data, 0)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		server := newKubeletServer(t, "a-token")
+		defer server.Close()
+
+		k := Kubelet{URL: server.URL, TokenFile: writeToken(t, "a-token")}
+		data, err := k.Gather()
+
+		assert.Error(t, err)
+		assert.Len(t, data, 0)
+	})
+
+	t.Run("missing token file", func(t *testing.T) {
+		k := Kubelet{TokenFile: "testdata/kubelet/missing"}
+		data, err := k.Gather()
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "token")
+		assert.Len(t, data, 0)
+	})
+}
@@ -40,6 +40,26 @@ const (
 	UnitNone            Unit = "None"
 )
 
+var units = []Unit{
+	UnitSeconds, UnitMicroseconds, UnitMilliseconds,
+	UnitBytes, UnitKilobytes, UnitMegabytes, UnitGigabytes, UnitTerabytes,
+	UnitBits, UnitKilobits, UnitMegabits, UnitGigabits, UnitTerabits,
+	UnitPercent, UnitCount,
+	UnitBytesSecond, UnitKilobytesSecond, UnitMegabytesSecond, UnitGigabytesSecond, UnitTerabytesSecond,
+	UnitBitsSecond, UnitKilobitsSecond, UnitMegabitsSecond, UnitGigabitsSecond, UnitTerabitsSecond,
+	UnitCountSecond, UnitNone,
+}
+
+// ParseUnit returns the Unit with the given name, ignoring case, or error if the name is not a known unit
+func ParseUnit(name string) (Unit, error) {
+	for _, u := range units {
+		if strings.EqualFold(string(u), name) {
+			return u, nil
+		}
+	}
+	return UnitNone, errors.Errorf("unknown unit [%s]", name)
+}
+
 // Dimension for a collected data point
 type Dimension struct {
 	Name  string
@@ -77,6 +97,9 @@ type Point struct {
 	Timestamp  time.Time
 	Value      float64
 	Unit       Unit
+	// Cumulative is set for monotonic counters whose value is the total since the source started,
+	// e.g. the bytes read by a container since it started, rather than a sample of the current value
+	Cumulative bool
 }
 
 // NewDataPoint creates a new data Point
@@ -86,6 +109,13 @@ func NewDataPoint(name string, value float64, unit Unit, dimensions ...Dimension
 	return p
 }
 
+// NewCumulativeDataPoint creates a new data Point for a monotonic counter
+func NewCumulativeDataPoint(name string, value float64, unit Unit, dimensions ...Dimension) Point {
+	p := NewDataPoint(name, value, unit, dimensions...)
+	p.Cumulative = true
+	return p
+}
+
 // AddDimensions adds the provided dimensions to the current data Point
 func (p *Point) AddDimensions(dimensions ...Dimension) {
 	if p.Dimensions == nil {
@@ -31,6 +31,31 @@ func TestNewDimension(t *testing.T) {
 	})
 }
 
+func TestParseUnit(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Unit
+	}{
+		{input: "Seconds", expected: UnitSeconds},
+		{input: "percent", expected: UnitPercent},
+		{input: "BYTES/SECOND", expected: UnitBytesSecond},
+		{input: "None", expected: UnitNone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			u, err := ParseUnit(tc.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, u)
+		})
+	}
+
+	t.Run("unknown unit", func(t *testing.T) {
+		_, err := ParseUnit("Hours")
+		assert.Error(t, err)
+	})
+}
+
 func TestMapToDimension(t *testing.T) {
 	t.Run("valid map", func(t *testing.T) {
 		input := map[string]string{"a": "1", "b": "2", "c": ""}
@@ -84,6 +109,17 @@ func TestNewDataPoint(t *testing.T) {
 	})
 }
 
+func TestNewCumulativeDataPoint(t *testing.T) {
+	dim := Dimension{"a", "1"}
+	p := NewCumulativeDataPoint("a", 5.0, UnitBytes, dim)
+	assert.Equal(t, "a", p.Name)
+	assert.Equal(t, 5.0, p.Value)
+	assert.Equal(t, UnitBytes, p.Unit)
+	assert.Equal(t, []Dimension{dim}, p.Dimensions)
+	assert.True(t, p.Cumulative)
+	assert.False(t, NewDataPoint("a", 5.0, UnitBytes).Cumulative)
+}
+
 func TestPoint_AddDimension(t *testing.T) {
 	p := NewDataPoint("a", 5.0, UnitCount)
 	assert.Len(t, p.Dimensions, 0)
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Nagios plugins exit codes
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+// nagiosUnits maps the units of measurement of the Nagios performance data to CloudWatch units
+var nagiosUnits = map[string]Unit{
+	"":   UnitNone,
+	"s":  UnitSeconds,
+	"ms": UnitMilliseconds,
+	"us": UnitMicroseconds,
+	"%":  UnitPercent,
+	"b":  UnitBytes,
+	"kb": UnitKilobytes,
+	"mb": UnitMegabytes,
+	"gb": UnitGigabytes,
+	"tb": UnitTerabytes,
+	"c":  UnitCount,
+}
+
+// splitPerfData returns the performance data section of the output of a plugin. Performance data
+// follows the first | of the first line and the first | of the following lines, if any.
+func splitPerfData(output string) string {
+	lines := strings.SplitN(output, "\n", 2)
+	perfData := []string{}
+	if i := strings.Index(lines[0], "|"); i >= 0 {
+		perfData = append(perfData, lines[0][i+1:])
+	}
+	if len(lines) > 1 {
+		if i := strings.Index(lines[1], "|"); i >= 0 {
+			perfData = append(perfData, lines[1][i+1:])
+		}
+	}
+	return strings.Join(perfData, " ")
+}
+
+// splitPerfDataItems splits the performance data in its items of the form label=value;warn;crit;min;max.
+// Labels containing spaces are enclosed in single quotes and a quote in the label is escaped by another quote.
+func splitPerfDataItems(perfData string) []string {
+	items := []string{}
+	var item strings.Builder
+	quoted := false
+	for i := 0; i < len(perfData); i++ {
+		c := perfData[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(perfData) && perfData[i+1] == '\'':
+			item.WriteByte(c)
+			item.WriteByte(c)
+			i++
+		case c == '\'':
+			quoted = !quoted
+			item.WriteByte(c)
+		case !quoted && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			if item.Len() > 0 {
+				items = append(items, item.String())
+				item.Reset()
+			}
+		default:
+			item.WriteByte(c)
+		}
+	}
+	if item.Len() > 0 {
+		items = append(items, item.String())
+	}
+	return items
+}
+
+// parsePerfDataItem parses a performance data item of the form label=value[UOM];warn;crit;min;max
+// into a data point. The thresholds and the range are ignored. It returns false if the value is undetermined.
+func parsePerfDataItem(item string, dimensions ...Dimension) (Point, bool, error) {
+	i := strings.LastIndex(item, "=")
+	if i <= 0 {
+		return Point{}, false, errors.Errorf("invalid performance data [%s]", item)
+	}
+
+	label := item[:i]
+	if strings.HasPrefix(label, "'") && strings.HasSuffix(label, "'") && len(label) > 1 {
+		label = strings.Replace(label[1:len(label)-1], "''", "'", -1)
+	}
+
+	value := strings.SplitN(item[i+1:], ";", 2)[0]
+	if value == "U" {
+		return Point{}, false, nil
+	}
+
+	numberEnd := strings.IndexFunc(value, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.-+eE", r)
+	})
+	number, uom := value, ""
+	if numberEnd >= 0 {
+		number, uom = value[:numberEnd], value[numberEnd:]
+	}
+
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return Point{}, false, errors.Wrapf(err, "invalid performance data value [%s]", item)
+	}
+	unit, ok := nagiosUnits[strings.ToLower(uom)]
+	if !ok {
+		return Point{}, false, errors.Errorf("invalid performance data unit [%s]", item)
+	}
+
+	return NewDataPoint(label, v, unit, dimensions...), true, nil
+}
+
+// parsePerfData parses the performance data in the output of a plugin into data points.
+// Malformed items are logged and skipped.
+func parsePerfData(check string, output string, dimensions ...Dimension) Data {
+	data := Data{}
+	for _, item := range splitPerfDataItems(splitPerfData(output)) {
+		p, ok, err := parsePerfDataItem(item, dimensions...)
+		if err != nil {
+			log.Warnf("skipping performance data of check [%s]: %s", check, err)
+			continue
+		}
+		if ok {
+			data = append(data, &p)
+		}
+	}
+	return data
+}
+
+// Nagios runs a list of Nagios compatible check plugins, e.g. check_disk -w 10% -c 5% -p /, with sh
+type Nagios struct {
+	Checks  []string
+	Timeout time.Duration
+}
+
+// Name of the Nagios metric
+func (n Nagios) Name() string {
+	return "nagios"
+}
+
+func (n Nagios) timeout() time.Duration {
+	if n.Timeout > 0 {
+		return n.Timeout
+	}
+	return defaultExecTimeout
+}
+
+// run the check and return its status and performance data
+func (n Nagios) run(check string) Data {
+	checkDim, _ := NewDimension("Check", check)
+
+	status := nagiosUnknown
+	data := Data{}
+	result, err := runCommand(check, n.timeout())
+	if err != nil {
+		log.Warn(err)
+	} else {
+		switch result.exitCode {
+		case nagiosOK, nagiosWarning, nagiosCritical:
+			status = result.exitCode
+		default:
+			log.Warnf("check [%s] exited with code %d: %s", check, result.exitCode, strings.TrimSpace(string(result.stderr)))
+		}
+		data = parsePerfData(check, string(result.stdout), checkDim)
+	}
+
+	statusPoint := NewDataPoint("CheckStatus", float64(status), UnitNone, checkDim)
+	return append(Data{&statusPoint}, data...)
+}
+
+// Gather runs all the checks in parallel and returns the following data points for every check,
+// with the Check dimension
+// - CheckStatus (none) 0 for OK, 1 for WARNING, 2 for CRITICAL and 3 for UNKNOWN, including checks
+// that failed to run or timed out
+// - a data point for every item of the performance data printed by the check, named after its label.
+// The units of measurement s, ms, us, %, B, KB, MB, GB, TB and c are converted to the CloudWatch units.
+func (n Nagios) Gather() (Data, error) {
+	log.Debug("gathering nagios checks")
+
+	results := make([]Data, len(n.Checks))
+	var wg sync.WaitGroup
+	for i, check := range n.Checks {
+		wg.Add(1)
+		go func(i int, check string) {
+			defer wg.Done()
+			results[i] = n.run(check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	data := Data{}
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPerfData(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{name: "no performance data", output: "DISK OK", expected: ""},
+		{name: "single line", output: "DISK OK | /=2643MB;5948;5958;0;5968\n", expected: " /=2643MB;5948;5958;0;5968"},
+		{
+			name:     "long output",
+			output:   "DISK OK | /=2643MB\n/ 15272 MB (77%);\n/boot 68 MB (69%); | /boot=68MB\n/var=1024MB\n",
+			expected: " /=2643MB  /boot=68MB\n/var=1024MB\n",
+		},
+		{name: "long output without performance data", output: "DISK OK\n/ 15272 MB (77%);\n", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, splitPerfData(tc.output))
+		})
+	}
+}
+
+func TestSplitPerfDataItems(t *testing.T) {
+	items := splitPerfDataItems(" time=0.01s;1;2 'free space'=12% 'it''s'=1\n size=10B ")
+	assert.Equal(t, []string{"time=0.01s;1;2", "'free space'=12%", "'it''s'=1", "size=10B"}, items)
+}
+
+func TestParsePerfDataItem(t *testing.T) {
+	testCases := []struct {
+		item     string
+		name     string
+		value    float64
+		unit     Unit
+		hasValue bool
+	}{
+		{item: "load1=0.15;5.0;10.0;0", name: "load1", value: 0.15, unit: UnitNone, hasValue: true},
+		{item: "time=0.012s;;;0", name: "time", value: 0.012, unit: UnitSeconds, hasValue: true},
+		{item: "rta=1.5ms", name: "rta", value: 1.5, unit: UnitMilliseconds, hasValue: true},
+		{item: "jitter=12us", name: "jitter", value: 12, unit: UnitMicroseconds, hasValue: true},
+		{item: "pl=0%;20;60", name: "pl", value: 0, unit: UnitPercent, hasValue: true},
+		{item: "/=2643MB;5948;5958;0;5968", name: "/", value: 2643, unit: UnitMegabytes, hasValue: true},
+		{item: "size=10B", name: "size", value: 10, unit: UnitBytes, hasValue: true},
+		{item: "size=1.5KB", name: "size", value: 1.5, unit: UnitKilobytes, hasValue: true},
+		{item: "size=2GB", name: "size", value: 2, unit: UnitGigabytes, hasValue: true},
+		{item: "size=3TB", name: "size", value: 3, unit: UnitTerabytes, hasValue: true},
+		{item: "requests=1234c", name: "requests", value: 1234, unit: UnitCount, hasValue: true},
+		{item: "temperature=-5", name: "temperature", value: -5, unit: UnitNone, hasValue: true},
+		{item: "'free space'=12%", name: "free space", value: 12, unit: UnitPercent, hasValue: true},
+		{item: "'it''s'=1", name: "it's", value: 1, unit: UnitNone, hasValue: true},
+		{item: "time=U;1;2", hasValue: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.item, func(t *testing.T) {
+			p, ok, err := parsePerfDataItem(tc.item)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.hasValue, ok)
+			if tc.hasValue {
+				assert.Equal(t, tc.name, p.Name)
+				assert.Equal(t, tc.value, p.Value)
+				assert.Equal(t, tc.unit, p.Unit)
+			}
+		})
+	}
+
+	invalid := []string{"time", "=1", "time=fast", "time=1h"}
+	for _, item := range invalid {
+		t.Run(item, func(t *testing.T) {
+			_, _, err := parsePerfDataItem(item)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNagios_Name(t *testing.T) {
+	n := Nagios{}
+	assert.Equal(t, "nagios", n.Name())
+}
+
+func TestNagios_Gather(t *testing.T) {
+	testCases := []struct {
+		name   string
+		check  string
+		status float64
+		points int
+	}{
+		{name: "ok", check: "echo 'PING OK | rta=1.5ms;100;500;0 pl=0%;20;60;0'", status: 0, points: 3},
+		{name: "warning", check: "echo 'DISK WARNING | /=90%;80;95'; exit 1", status: 1, points: 2},
+		{name: "critical", check: "echo 'DISK CRITICAL | /=99%;80;95 bad=1h'; exit 2", status: 2, points: 2},
+		{name: "unknown", check: "echo 'UNKNOWN - invalid arguments'; exit 3", status: 3, points: 1},
+		{name: "unexpected exit code", check: "exit 127", status: 3, points: 1},
+		{name: "timeout", check: "sleep 10", status: 3, points: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := Nagios{Checks: []string{tc.check}, Timeout: 50 * time.Millisecond}
+			data, err := n.Gather()
+
+			assert.NoError(t, err)
+			assert.Len(t, data, tc.points)
+			checkDim := Dimension{Name: "Check", Value: tc.check}
+			assert.Equal(t, tc.status, findPoint(data, "CheckStatus", checkDim).Value)
+		})
+	}
+
+	t.Run("performance data", func(t *testing.T) {
+		check := "echo 'PING OK | rta=1.5ms;100;500;0 pl=0%;20;60;0'"
+		data, err := Nagios{Checks: []string{check}}.Gather()
+
+		assert.NoError(t, err)
+		checkDim := Dimension{Name: "Check", Value: check}
+		assert.Equal(t, 1.5, findPoint(data, "rta", checkDim).Value)
+		assert.Equal(t, UnitMilliseconds, findPoint(data, "rta", checkDim).Unit)
+		assert.Equal(t, 0.0, findPoint(data, "pl", checkDim).Value)
+		assert.Equal(t, UnitPercent, findPoint(data, "pl", checkDim).Unit)
+	})
+}
@@ -0,0 +1,498 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultPrometheusTimeout = 10 * time.Second
+
+// prometheusMaxLabels is the maximum number of labels reported as dimensions, keeping room for the Instance
+// and Host dimensions within the 30 dimensions accepted by CloudWatch
+const prometheusMaxLabels = 28
+
+// prometheusQuantiles are the quantiles reported for histograms
+var prometheusQuantiles = []struct {
+	suffix   string
+	quantile float64
+}{{"_p50", 0.5}, {"_p90", 0.9}, {"_p99", 0.99}}
+
+// promSample is a sample of the Prometheus text exposition format
+type promSample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// key identifies the series of the sample
+func (s promSample) key() string {
+	names := make([]string, 0, len(s.labels))
+	for name := range s.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names)+1)
+	parts = append(parts, s.name)
+	for _, name := range names {
+		parts = append(parts, name+"="+s.labels[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+// parsePromLabels parses the labels of a sample of the form {name="value",...} returning the labels
+// and the rest of the line following the closing brace
+func parsePromLabels(line string) (map[string]string, string, error) {
+	labels := map[string]string{}
+	i := 1
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == ',') {
+			i++
+		}
+		if i >= len(line) {
+			return nil, "", errors.New("unterminated labels")
+		}
+		if line[i] == '}' {
+			return labels, line[i+1:], nil
+		}
+
+		eq := strings.IndexByte(line[i:], '=')
+		if eq < 0 || i+eq+1 >= len(line) || line[i+eq+1] != '"' {
+			return nil, "", errors.New("invalid label")
+		}
+		name := strings.TrimSpace(line[i : i+eq])
+		i += eq + 2
+
+		var value strings.Builder
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+				switch line[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(line[i])
+				}
+				continue
+			}
+			value.WriteByte(line[i])
+		}
+		if i >= len(line) {
+			return nil, "", errors.New("unterminated label value")
+		}
+		labels[name] = value.String()
+		i++
+	}
+}
+
+// parsePromLine parses a sample line of the form name{label="value",...} value [timestamp]
+func parsePromLine(line string) (promSample, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return promSample{}, errors.Errorf("invalid sample [%s]", line)
+	}
+
+	s := promSample{name: line[:end], labels: map[string]string{}}
+	rest := line[end:]
+	if strings.HasPrefix(rest, "{") {
+		labels, r, err := parsePromLabels(rest)
+		if err != nil {
+			return promSample{}, errors.Wrapf(err, "invalid sample [%s]", line)
+		}
+		s.labels, rest = labels, r
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return promSample{}, errors.Errorf("invalid sample [%s]: missing value", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return promSample{}, errors.Wrapf(err, "invalid sample [%s]", line)
+	}
+	s.value = value
+	return s, nil
+}
+
+// parsePromText parses the Prometheus text exposition format returning the samples and the type of the
+// metric families declared by the TYPE comments. Malformed lines are logged and skipped.
+func parsePromText(r io.Reader) ([]promSample, map[string]string, error) {
+	samples := []promSample{}
+	types := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		s, err := parsePromLine(line)
+		if err != nil {
+			log.Debug(err)
+			continue
+		}
+		samples = append(samples, s)
+	}
+	return samples, types, scanner.Err()
+}
+
+// promKind returns how a sample is reported: as a rate for counters and the sums and counts of
+// histograms and summaries, as a gauge for gauges, untyped metrics and the quantiles of summaries,
+// or as a bucket for the buckets of histograms, whose quantiles are reported instead
+func promKind(name string, types map[string]string) (family, kind string) {
+	if t, ok := types[name]; ok {
+		switch t {
+		case "counter":
+			return name, "rate"
+		case "histogram":
+			return name, "skip"
+		default:
+			return name, "gauge"
+		}
+	}
+
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		family := strings.TrimSuffix(name, suffix)
+		if family == name {
+			continue
+		}
+		switch t := types[family]; {
+		case t == "histogram" && suffix == "_bucket":
+			return family, "bucket"
+		case t == "histogram" || t == "summary":
+			return family, "rate"
+		}
+	}
+	return name, "gauge"
+}
+
+// promRateUnit returns the unit of the per second rate of a sample. Following the Prometheus naming
+// conventions the rates of bytes are reported as bytes/second while the rates of seconds, e.g. the CPU
+// time or the sum of the durations of a histogram, are a fraction of time and have no unit. The rates
+// of other counters and of the counts of histograms and summaries are reported as count/second while
+// the rates of other sums, whose unit is not known, have no unit.
+func promRateUnit(name, family string) Unit {
+	base := strings.TrimSuffix(strings.TrimSuffix(name, "_total"), "_sum")
+	switch {
+	case strings.HasSuffix(base, "_bytes"):
+		return UnitBytesSecond
+	case strings.HasSuffix(base, "_seconds"):
+		return UnitNone
+	case name == family+"_sum":
+		return UnitNone
+	default:
+		return UnitCountSecond
+	}
+}
+
+// promBucket is the rate of the observations of a histogram bucket with the given upper bound
+type promBucket struct {
+	upperBound float64
+	rate       float64
+}
+
+// promHistogram collects the buckets of a histogram series
+type promHistogram struct {
+	family     string
+	dimensions []Dimension
+	buckets    []promBucket
+	complete   bool
+}
+
+// promQuantile estimates the quantile of the observations of a histogram by linear interpolation within the
+// bucket containing it, as the histogram_quantile function of Prometheus. It returns false if the histogram
+// has no observations or lacks the +Inf bucket. Quantiles falling in the +Inf bucket are estimated as the
+// upper bound of the highest finite bucket.
+func promQuantile(q float64, buckets []promBucket) (float64, bool) {
+	sorted := make([]promBucket, len(buckets))
+	copy(sorted, buckets)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].upperBound < sorted[j].upperBound })
+	if len(sorted) < 2 || !math.IsInf(sorted[len(sorted)-1].upperBound, 1) {
+		return 0, false
+	}
+
+	total := sorted[len(sorted)-1].rate
+	if total <= 0 {
+		return 0, false
+	}
+
+	rank := q * total
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].rate >= rank })
+	if i == len(sorted)-1 {
+		return sorted[len(sorted)-2].upperBound, true
+	}
+
+	lowerBound, lowerRate := 0.0, 0.0
+	if i > 0 {
+		lowerBound, lowerRate = sorted[i-1].upperBound, sorted[i-1].rate
+	} else if sorted[0].upperBound <= 0 {
+		return sorted[0].upperBound, true
+	}
+	if sorted[i].rate == lowerRate {
+		return sorted[i].upperBound, true
+	}
+	return lowerBound + (sorted[i].upperBound-lowerBound)*(rank-lowerRate)/(sorted[i].rate-lowerRate), true
+}
+
+// promCounter is the previous value of a counter used to compute its rate
+type promCounter struct {
+	value float64
+	time  time.Time
+}
+
+// PrometheusScrape collects the metrics exposed in the Prometheus text format by a list of URLs.
+// Only the metrics whose name, or the name of their family, matches one of the Allow patterns,
+// e.g. http_requests_*, are reported, all metrics if Allow is empty. Labels are reported as dimensions
+// named after the Labels mapping, with labels not in the mapping dropped, or with the name of the label
+// if Labels is empty.
+type PrometheusScrape struct {
+	URLs    []string
+	Allow   []string
+	Labels  map[string]string
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	counters map[string]promCounter
+}
+
+// NewPrometheusScrape creates a collector scraping the given URLs
+func NewPrometheusScrape(urls, allow []string, labels map[string]string) *PrometheusScrape {
+	return &PrometheusScrape{URLs: urls, Allow: allow, Labels: labels, counters: map[string]promCounter{}}
+}
+
+// Name of the PrometheusScrape metric
+func (p *PrometheusScrape) Name() string {
+	return "prometheus"
+}
+
+func (p *PrometheusScrape) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return defaultPrometheusTimeout
+}
+
+func (p *PrometheusScrape) allowed(names ...string) bool {
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range p.Allow {
+		for _, name := range names {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dimensions returns the dimensions of the sample, sorted by name, with the Instance dimension
+// identifying the scraped URL. Labels with an empty value, rejected by CloudWatch, are dropped and
+// only the first prometheusMaxLabels labels by name are kept, together with the quantile of summaries.
+func (p *PrometheusScrape) dimensions(instance string, labels map[string]string) []Dimension {
+	instanceDim, _ := NewDimension("Instance", instance)
+	dimensions := []Dimension{}
+	for label, value := range labels {
+		if value == "" {
+			continue
+		}
+		name := label
+		if len(p.Labels) > 0 {
+			mapped, ok := p.Labels[label]
+			if !ok && label != "quantile" {
+				continue
+			}
+			if mapped != "" {
+				name = mapped
+			}
+		}
+		if d, err := NewDimension(name, value); err == nil {
+			dimensions = append(dimensions, d)
+		}
+	}
+	sort.Slice(dimensions, func(i, j int) bool { return dimensions[i].Name < dimensions[j].Name })
+
+	if len(dimensions) > prometheusMaxLabels {
+		kept := make([]Dimension, 0, prometheusMaxLabels+1)
+		for i, d := range dimensions {
+			if i < prometheusMaxLabels || d.Name == "quantile" {
+				kept = append(kept, d)
+			}
+		}
+		log.Debugf("dropping %d labels exceeding the %d labels reported as dimensions", len(dimensions)-len(kept), prometheusMaxLabels)
+		dimensions = kept
+	}
+	return append([]Dimension{instanceDim}, dimensions...)
+}
+
+// rate returns the per second rate of the counter since the previous scrape. It returns false
+// on the first scrape and when the counter has been reset.
+func (p *PrometheusScrape) rate(key string, value float64, now time.Time) (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	previous, ok := p.counters[key]
+	p.counters[key] = promCounter{value: value, time: now}
+	elapsed := now.Sub(previous.time).Seconds()
+	if !ok || value < previous.value || elapsed <= 0 {
+		return 0, false
+	}
+	return (value - previous.value) / elapsed, true
+}
+
+// prune removes the counters of the target not seen in its latest scrape, e.g. with labels that changed
+func (p *PrometheusScrape) prune(target string, seen map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key := range p.counters {
+		if strings.HasPrefix(key, target+"|") && !seen[key] {
+			delete(p.counters, key)
+		}
+	}
+}
+
+// scrape fetches the metrics exposed by the URL
+func (p *PrometheusScrape) scrape(target string) (Data, error) {
+	client := http.Client{Timeout: p.timeout()}
+	response, err := client.Get(target)
+	if err != nil {
+		return Data{}, errors.Wrapf(err, "failed to scrape [%s]", target)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Data{}, errors.Errorf("failed to scrape [%s]: unexpected status [%s]", target, response.Status)
+	}
+
+	samples, types, err := parsePromText(response.Body)
+	if err != nil {
+		return Data{}, errors.Wrapf(err, "failed to read metrics from [%s]", target)
+	}
+
+	instance := target
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		instance = u.Host
+	}
+
+	now := time.Now()
+	data := Data{}
+	seen := map[string]bool{}
+	histograms := []*promHistogram{}
+	histogramsByKey := map[string]*promHistogram{}
+	for _, s := range samples {
+		family, kind := promKind(s.name, types)
+		if kind == "skip" || !p.allowed(s.name, family) || math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+
+		switch kind {
+		case "bucket":
+			upperBound, err := strconv.ParseFloat(s.labels["le"], 64)
+			if err != nil {
+				continue
+			}
+			labels := make(map[string]string, len(s.labels))
+			for name, value := range s.labels {
+				if name != "le" {
+					labels[name] = value
+				}
+			}
+			histogramKey := promSample{name: family, labels: labels}.key()
+			h, ok := histogramsByKey[histogramKey]
+			if !ok {
+				h = &promHistogram{family: family, dimensions: p.dimensions(instance, labels), complete: true}
+				histogramsByKey[histogramKey] = h
+				histograms = append(histograms, h)
+			}
+
+			key := target + "|" + s.key()
+			seen[key] = true
+			rate, ok := p.rate(key, s.value, now)
+			h.buckets = append(h.buckets, promBucket{upperBound: upperBound, rate: rate})
+			h.complete = h.complete && ok
+		case "rate":
+			key := target + "|" + s.key()
+			seen[key] = true
+			if rate, ok := p.rate(key, s.value, now); ok {
+				point := NewDataPoint(s.name, rate, promRateUnit(s.name, family), p.dimensions(instance, s.labels)...)
+				data = append(data, &point)
+			}
+		default:
+			point := NewDataPoint(s.name, s.value, UnitNone, p.dimensions(instance, s.labels)...)
+			data = append(data, &point)
+		}
+	}
+	p.prune(target, seen)
+
+	for _, h := range histograms {
+		if !h.complete {
+			continue
+		}
+		for _, q := range prometheusQuantiles {
+			if value, ok := promQuantile(q.quantile, h.buckets); ok {
+				point := NewDataPoint(h.family+q.suffix, value, UnitNone, h.dimensions...)
+				data = append(data, &point)
+			}
+		}
+	}
+	return data, nil
+}
+
+// Gather scrapes all the URLs in parallel and returns a data point for every sample, with the Instance
+// dimension given by the host of the URL and the labels of the sample as dimensions:
+// - counters, and the _sum and _count of histograms and summaries, are reported as their per second rate
+// since the previous scrape hence they are only reported from the second scrape. The unit of the rate is
+// given by promRateUnit, e.g. count/second for the counts and none for the sums of durations
+// - gauges, untyped metrics and the quantiles of summaries are reported as they are (none)
+// - the 50th, 90th and 99th percentiles of the observations of histograms since the previous scrape are
+// estimated from their buckets and reported as <name>_p50, <name>_p90 and <name>_p99 (none), from the
+// second scrape and only if there were observations
+// - NaN and infinite values are skipped
+// URLs that cannot be scraped are logged and skipped.
+func (p *PrometheusScrape) Gather() (Data, error) {
+	log.Debug("gathering prometheus metrics")
+
+	results := make([]Data, len(p.URLs))
+	var wg sync.WaitGroup
+	for i, target := range p.URLs {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			data, err := p.scrape(target)
+			if err != nil {
+				log.Warn(err)
+			}
+			results[i] = data
+		}(i, target)
+	}
+	wg.Wait()
+
+	data := Data{}
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
@@ -0,0 +1,281 @@
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePromLine(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected promSample
+	}{
+		{line: "up 1", expected: promSample{name: "up", labels: map[string]string{}, value: 1}},
+		{line: "up{} 0 1395066363000", expected: promSample{name: "up", labels: map[string]string{}, value: 0}},
+		{
+			line:     `http_requests_total{method="post",code="200",} 1027`,
+			expected: promSample{name: "http_requests_total", labels: map[string]string{"method": "post", "code": "200"}, value: 1027},
+		},
+		{
+			line:     `msg{text="a \"quoted\", \\escaped\nvalue"} -1.5e3`,
+			expected: promSample{name: "msg", labels: map[string]string{"text": "a \"quoted\", \\escaped\nvalue"}, value: -1500},
+		},
+		{
+			line:     `bucket{le="+Inf"} +Inf`,
+			expected: promSample{name: "bucket", labels: map[string]string{"le": "+Inf"}, value: math.Inf(1)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			s, err := parsePromLine(tc.line)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, s)
+		})
+	}
+
+	invalid := []string{"up", "{a=\"b\"} 1", `up{a="b" 1`, `up{a=b} 1`, "up one"}
+	for _, line := range invalid {
+		t.Run(line, func(t *testing.T) {
+			_, err := parsePromLine(line)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParsePromText(t *testing.T) {
+	f, err := os.Open("testdata/prometheus/metrics.txt")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	samples, types, err := parsePromText(f)
+	assert.NoError(t, err)
+	assert.Len(t, samples, 13)
+	assert.Equal(t, map[string]string{
+		"http_requests_total":      "counter",
+		"queue_size":               "gauge",
+		"request_duration_seconds": "histogram",
+		"rpc_duration_seconds":     "summary",
+	}, types)
+}
+
+func TestPromKind(t *testing.T) {
+	types := map[string]string{
+		"requests_total":   "counter",
+		"queue_size":       "gauge",
+		"duration_seconds": "histogram",
+		"rpc_seconds":      "summary",
+	}
+
+	testCases := []struct {
+		name   string
+		family string
+		kind   string
+	}{
+		{name: "requests_total", family: "requests_total", kind: "rate"},
+		{name: "queue_size", family: "queue_size", kind: "gauge"},
+		{name: "build_info", family: "build_info", kind: "gauge"},
+		{name: "duration_seconds_bucket", family: "duration_seconds", kind: "bucket"},
+		{name: "duration_seconds_sum", family: "duration_seconds", kind: "rate"},
+		{name: "duration_seconds_count", family: "duration_seconds", kind: "rate"},
+		{name: "rpc_seconds", family: "rpc_seconds", kind: "gauge"},
+		{name: "rpc_seconds_count", family: "rpc_seconds", kind: "rate"},
+		{name: "queue_size_count", family: "queue_size_count", kind: "gauge"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			family, kind := promKind(tc.name, types)
+			assert.Equal(t, tc.family, family)
+			assert.Equal(t, tc.kind, kind)
+		})
+	}
+}
+
+func TestPromRateUnit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		family   string
+		expected Unit
+	}{
+		{name: "http_requests_total", family: "http_requests_total", expected: UnitCountSecond},
+		{name: "network_received_bytes_total", family: "network_received_bytes_total", expected: UnitBytesSecond},
+		{name: "process_cpu_seconds_total", family: "process_cpu_seconds_total", expected: UnitNone},
+		{name: "request_duration_seconds_sum", family: "request_duration_seconds", expected: UnitNone},
+		{name: "request_duration_seconds_count", family: "request_duration_seconds", expected: UnitCountSecond},
+		{name: "response_size_bytes_sum", family: "response_size_bytes", expected: UnitBytesSecond},
+		{name: "batch_size_sum", family: "batch_size", expected: UnitNone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, promRateUnit(tc.name, tc.family))
+		})
+	}
+}
+
+func TestPromQuantile(t *testing.T) {
+	buckets := []promBucket{
+		{upperBound: math.Inf(1), rate: 100},
+		{upperBound: 0.1, rate: 50},
+		{upperBound: 0.5, rate: 90},
+	}
+
+	testCases := []struct {
+		quantile float64
+		expected float64
+	}{
+		{quantile: 0.25, expected: 0.05},
+		{quantile: 0.5, expected: 0.1},
+		{quantile: 0.7, expected: 0.3},
+		{quantile: 0.99, expected: 0.5},
+	}
+	for _, tc := range testCases {
+		value, ok := promQuantile(tc.quantile, buckets)
+		assert.True(t, ok)
+		assert.InDelta(t, tc.expected, value, 1e-9, "quantile %f", tc.quantile)
+	}
+
+	_, ok := promQuantile(0.5, []promBucket{{upperBound: 0.1, rate: 1}, {upperBound: math.Inf(1), rate: 0}})
+	assert.False(t, ok, "no observations")
+	_, ok = promQuantile(0.5, []promBucket{{upperBound: 0.1, rate: 1}, {upperBound: 0.5, rate: 2}})
+	assert.False(t, ok, "missing +Inf bucket")
+}
+
+func TestPrometheusScrape_Rate(t *testing.T) {
+	p := NewPrometheusScrape(nil, nil, nil)
+	now := time.Now()
+
+	_, ok := p.rate("key", 100, now)
+	assert.False(t, ok)
+
+	rate, ok := p.rate("key", 160, now.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, rate)
+
+	// a counter reset is skipped
+	_, ok = p.rate("key", 10, now.Add(60*time.Second))
+	assert.False(t, ok)
+
+	rate, ok = p.rate("key", 40, now.Add(90*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, rate)
+}
+
+func TestPrometheusScrape_prune(t *testing.T) {
+	p := NewPrometheusScrape(nil, nil, nil)
+	now := time.Now()
+	for _, key := range []string{"a|x", "a|y", "b|x"} {
+		p.rate(key, 1, now)
+	}
+
+	p.prune("a", map[string]bool{"a|x": true})
+	assert.Len(t, p.counters, 2)
+	assert.Contains(t, p.counters, "a|x")
+	assert.Contains(t, p.counters, "b|x")
+}
+
+func TestPrometheusScrape_Dimensions(t *testing.T) {
+	labels := map[string]string{"method": "post", "code": "200", "quantile": "0.5", "empty": ""}
+
+	t.Run("all labels", func(t *testing.T) {
+		p := NewPrometheusScrape(nil, nil, nil)
+		assert.Equal(t, []Dimension{
+			{"Instance", "host:9090"}, {"code", "200"}, {"method", "post"}, {"quantile", "0.5"},
+		}, p.dimensions("host:9090", labels))
+	})
+
+	t.Run("mapped labels", func(t *testing.T) {
+		p := NewPrometheusScrape(nil, nil, map[string]string{"method": "Method", "code": ""})
+		assert.Equal(t, []Dimension{
+			{"Instance", "host:9090"}, {"Method", "post"}, {"code", "200"}, {"quantile", "0.5"},
+		}, p.dimensions("host:9090", labels))
+	})
+
+	t.Run("labels exceeding the dimensions limit", func(t *testing.T) {
+		many := map[string]string{"quantile": "0.5"}
+		for i := 0; i < 40; i++ {
+			many[fmt.Sprintf("label%02d", i)] = "value"
+		}
+
+		p := NewPrometheusScrape(nil, nil, nil)
+		dimensions := p.dimensions("host:9090", many)
+		assert.Len(t, dimensions, 1+prometheusMaxLabels+1)
+		assert.Equal(t, "label00", dimensions[1].Name)
+		assert.Equal(t, Dimension{"quantile", "0.5"}, dimensions[len(dimensions)-1])
+	})
+}
+
+func TestPrometheusScrape_Name(t *testing.T) {
+	p := NewPrometheusScrape(nil, nil, nil)
+	assert.Equal(t, "prometheus", p.Name())
+}
+
+func TestPrometheusScrape_Gather(t *testing.T) {
+	exposition, err := ioutil.ReadFile("testdata/prometheus/metrics.txt")
+	assert.NoError(t, err)
+
+	var scrapes int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		n := atomic.AddInt64(&scrapes, 1)
+		// the counters increase on every scrape
+		body := strings.Replace(string(exposition), "} 1027 ", fmt.Sprintf("} %d ", 1027+n*100), 1)
+		// as the observations of the histogram, half of which in the lowest bucket
+		body = strings.Replace(body, `{le="0.05"} 24054`, fmt.Sprintf(`{le="0.05"} %d`, 24054+n*50), 1)
+		body = strings.Replace(body, `{le="+Inf"} 144320`, fmt.Sprintf(`{le="+Inf"} %d`, 144320+n*100), 1)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	instance := strings.TrimPrefix(server.URL, "http://")
+
+	t.Run("gauges on the first scrape and rates from the second", func(t *testing.T) {
+		p := NewPrometheusScrape([]string{server.URL + "/metrics", server.URL + "/missing"}, nil, nil)
+
+		data, err := p.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 4)
+		assert.Equal(t, 12.0, findPoint(data, "queue_size", Dimension{"Instance", instance}, Dimension{"queue", `jobs "high"`}).Value)
+		assert.Equal(t, 1.0, findPoint(data, "build_info", Dimension{"Instance", instance}, Dimension{"version", "1.2.3"}).Value)
+		assert.Equal(t, 4773.0, findPoint(data, "rpc_duration_seconds", Dimension{"Instance", instance}, Dimension{"quantile", "0.5"}).Value)
+
+		time.Sleep(10 * time.Millisecond)
+		data, err = p.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 4+6+3)
+
+		requests := findPoint(data, "http_requests_total", Dimension{"Instance", instance}, Dimension{"code", "200"}, Dimension{"method", "post"})
+		assert.True(t, requests.Value > 0)
+		assert.Equal(t, UnitCountSecond, requests.Unit)
+		assert.Equal(t, 0.0, findPoint(data, "http_requests_total", Dimension{"Instance", instance}, Dimension{"code", "400"}, Dimension{"method", "post"}).Value)
+		assert.Equal(t, UnitCountSecond, findPoint(data, "request_duration_seconds_count", Dimension{"Instance", instance}).Unit)
+		assert.Equal(t, UnitNone, findPoint(data, "request_duration_seconds_sum", Dimension{"Instance", instance}).Unit)
+		assert.Nil(t, findPoint(data, "request_duration_seconds_bucket", Dimension{"Instance", instance}, Dimension{"le", "0.05"}))
+		assert.Equal(t, 0.05, findPoint(data, "request_duration_seconds_p50", Dimension{"Instance", instance}).Value)
+		assert.Equal(t, 0.05, findPoint(data, "request_duration_seconds_p99", Dimension{"Instance", instance}).Value)
+	})
+
+	t.Run("allowlist", func(t *testing.T) {
+		p := NewPrometheusScrape([]string{server.URL + "/metrics"}, []string{"queue_*", "rpc_duration_seconds"}, nil)
+
+		data, err := p.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 3)
+		for _, point := range data {
+			assert.NotEqual(t, "build_info", point.Name)
+		}
+	})
+}
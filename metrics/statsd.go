@@ -0,0 +1,410 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultStatsDAddress = ":8125"
+	maxStatsDPacketSize  = 65535
+)
+
+var defaultStatsDPercentiles = []float64{90}
+
+// statsdMetric is a metric received by the StatsD listener, aggregated by name, type and tags
+type statsdMetric struct {
+	name       string
+	kind       string
+	dimensions []Dimension
+	unit       Unit
+	value      float64
+	values     []float64
+	set        map[string]bool
+}
+
+// statsdSample is a sample parsed from a StatsD line
+type statsdSample struct {
+	name       string
+	value      string
+	kind       string
+	sampleRate float64
+	dimensions []Dimension
+}
+
+// key identifies the metric a sample is aggregated into
+func (s statsdSample) key() string {
+	parts := make([]string, 0, len(s.dimensions)+2)
+	parts = append(parts, s.name, s.kind)
+	for _, d := range s.dimensions {
+		parts = append(parts, d.Name+"="+d.Value)
+	}
+	return strings.Join(parts, "|")
+}
+
+// parseStatsDLine parses a line of the form name:value|type[|@sample_rate][|#tag:value,...]
+// where type is one of c (counter), g (gauge), ms (timer), h and d (histogram and distribution,
+// aggregated as timers) and s (set). Tags are sorted by name and tags without a value are ignored.
+func parseStatsDLine(line string) (statsdSample, error) {
+	i := strings.LastIndex(strings.SplitN(line, "|", 2)[0], ":")
+	if i <= 0 {
+		return statsdSample{}, errors.Errorf("invalid statsd line [%s]: missing name", line)
+	}
+
+	fields := strings.Split(line[i+1:], "|")
+	if len(fields) < 2 || fields[0] == "" {
+		return statsdSample{}, errors.Errorf("invalid statsd line [%s]: missing value or type", line)
+	}
+
+	s := statsdSample{name: line[:i], value: fields[0], kind: fields[1], sampleRate: 1}
+	switch s.kind {
+	case "c", "g", "ms", "h", "d", "s":
+	default:
+		return statsdSample{}, errors.Errorf("invalid statsd line [%s]: unknown type [%s]", line, s.kind)
+	}
+	if s.kind == "h" || s.kind == "d" {
+		s.kind = "ms"
+	}
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return statsdSample{}, errors.Errorf("invalid statsd line [%s]: invalid sample rate [%s]", line, field)
+			}
+			s.sampleRate = rate
+		case strings.HasPrefix(field, "#"):
+			s.dimensions = parseStatsDTags(field[1:])
+		}
+	}
+
+	if s.kind != "s" {
+		if _, err := strconv.ParseFloat(strings.TrimPrefix(s.value, "+"), 64); err != nil {
+			return statsdSample{}, errors.Errorf("invalid statsd line [%s]: invalid value [%s]", line, s.value)
+		}
+	}
+	return s, nil
+}
+
+// parseStatsDTags converts the DogStatsD tags of the form tag:value,... into dimensions sorted by name
+func parseStatsDTags(tags string) []Dimension {
+	dimensions := []Dimension{}
+	for _, tag := range strings.Split(tags, ",") {
+		kv := strings.SplitN(tag, ":", 2)
+		if len(kv) != 2 {
+			log.Debugf("ignoring statsd tag without value [%s]", tag)
+			continue
+		}
+		if d, err := NewDimension(kv[0], kv[1]); err == nil {
+			dimensions = append(dimensions, d)
+		}
+	}
+	sort.Slice(dimensions, func(i, j int) bool { return dimensions[i].Name < dimensions[j].Name })
+	return dimensions
+}
+
+// percentile returns the nearest rank percentile of the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// formatPercentile returns the suffix of the name of a percentile data point, e.g. p90 or p99_9
+func formatPercentile(p float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", -1)
+}
+
+// StatsD listens for metrics pushed by applications with the StatsD protocol, including DogStatsD tags,
+// on the UDP Address and, if set, on the TCP TCPAddress. Metrics are aggregated between two calls to Gather.
+type StatsD struct {
+	Address     string
+	TCPAddress  string
+	Percentiles []float64
+
+	mu       sync.Mutex
+	started  bool
+	conn     net.PacketConn
+	listener net.Listener
+	tcpConns map[net.Conn]bool
+	wg       sync.WaitGroup
+	metrics  map[string]*statsdMetric
+	gauges   map[string]*statsdMetric
+}
+
+// NewStatsD creates a StatsD listener for the given addresses reporting the given percentiles for timers
+func NewStatsD(address, tcpAddress string, percentiles []float64) *StatsD {
+	return &StatsD{
+		Address:     address,
+		TCPAddress:  tcpAddress,
+		Percentiles: percentiles,
+		metrics:     map[string]*statsdMetric{},
+		gauges:      map[string]*statsdMetric{},
+		tcpConns:    map[net.Conn]bool{},
+	}
+}
+
+// Name of the StatsD metric
+func (s *StatsD) Name() string {
+	return "statsd"
+}
+
+func (s *StatsD) address() string {
+	if s.Address != "" {
+		return s.Address
+	}
+	return defaultStatsDAddress
+}
+
+func (s *StatsD) percentiles() []float64 {
+	if len(s.Percentiles) > 0 {
+		return s.Percentiles
+	}
+	return defaultStatsDPercentiles
+}
+
+// start the listeners if not already running
+func (s *StatsD) start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return nil
+	}
+
+	conn, err := net.ListenPacket("udp", s.address())
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen for statsd metrics on udp [%s]", s.address())
+	}
+
+	var listener net.Listener
+	if s.TCPAddress != "" {
+		if listener, err = net.Listen("tcp", s.TCPAddress); err != nil {
+			conn.Close()
+			return errors.Wrapf(err, "failed to listen for statsd metrics on tcp [%s]", s.TCPAddress)
+		}
+	}
+
+	log.Infof("listening for statsd metrics on udp [%s]", conn.LocalAddr())
+	s.conn, s.listener, s.started = conn, listener, true
+	s.wg.Add(1)
+	go s.serveUDP(conn)
+	if listener != nil {
+		log.Infof("listening for statsd metrics on tcp [%s]", listener.Addr())
+		s.wg.Add(1)
+		go s.serveTCP(listener)
+	}
+	return nil
+}
+
+func (s *StatsD) serveUDP(conn net.PacketConn) {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxStatsDPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.handle(line)
+		}
+	}
+}
+
+func (s *StatsD) serveTCP(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if !s.started {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.tcpConns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.tcpConns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				s.handle(scanner.Text())
+			}
+		}()
+	}
+}
+
+// handle parses a line and aggregates the sample
+func (s *StatsD) handle(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	sample, err := parseStatsDLine(line)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+	s.add(sample)
+}
+
+// add aggregates the sample into its metric
+func (s *StatsD) add(sample statsdSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	aggregated := s.metrics
+	if sample.kind == "g" {
+		aggregated = s.gauges
+	}
+
+	key := sample.key()
+	m, ok := aggregated[key]
+	if !ok {
+		m = &statsdMetric{name: sample.name, kind: sample.kind, dimensions: sample.dimensions, unit: UnitNone, set: map[string]bool{}}
+		aggregated[key] = m
+	}
+
+	value, _ := strconv.ParseFloat(strings.TrimPrefix(sample.value, "+"), 64)
+	switch sample.kind {
+	case "c":
+		m.unit = UnitCount
+		m.value += value / sample.sampleRate
+	case "g":
+		// gauges with an explicit sign are changed by the value instead of being set to it
+		if strings.HasPrefix(sample.value, "+") || strings.HasPrefix(sample.value, "-") {
+			m.value += value
+		} else {
+			m.value = value
+		}
+	case "ms":
+		m.unit = UnitMilliseconds
+		// the value of a timer counts the samples, scaled by the sample rate like counters
+		m.value += 1 / sample.sampleRate
+		m.values = append(m.values, value)
+	case "s":
+		m.unit = UnitCount
+		m.set[sample.value] = true
+	}
+}
+
+// flush returns the data points for the metrics aggregated since the previous flush
+func (s *StatsD) flush() Data {
+	s.mu.Lock()
+	aggregated := s.metrics
+	s.metrics = map[string]*statsdMetric{}
+	gauges := make([]statsdMetric, 0, len(s.gauges))
+	for _, g := range s.gauges {
+		gauges = append(gauges, *g)
+	}
+	s.mu.Unlock()
+
+	data := Data{}
+	for _, m := range aggregated {
+		switch m.kind {
+		case "ms":
+			data = append(data, s.timerData(m)...)
+		case "s":
+			p := NewDataPoint(m.name, float64(len(m.set)), UnitCount, m.dimensions...)
+			data = append(data, &p)
+		default:
+			p := NewDataPoint(m.name, m.value, m.unit, m.dimensions...)
+			data = append(data, &p)
+		}
+	}
+	for _, g := range gauges {
+		p := NewDataPoint(g.name, g.value, g.unit, g.dimensions...)
+		data = append(data, &p)
+	}
+
+	sort.SliceStable(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	return data
+}
+
+// timerData returns the count, sum, minimum, maximum and percentiles data points of a timer
+func (s *StatsD) timerData(m *statsdMetric) Data {
+	sorted := append([]float64{}, m.values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	count := NewDataPoint(m.name+".count", m.value, UnitCount, m.dimensions...)
+	sumPoint := NewDataPoint(m.name+".sum", sum, m.unit, m.dimensions...)
+	min := NewDataPoint(m.name+".min", sorted[0], m.unit, m.dimensions...)
+	max := NewDataPoint(m.name+".max", sorted[len(sorted)-1], m.unit, m.dimensions...)
+	data := Data{&count, &sumPoint, &min, &max}
+	for _, p := range s.percentiles() {
+		point := NewDataPoint(m.name+"."+formatPercentile(p), percentile(sorted, p), m.unit, m.dimensions...)
+		data = append(data, &point)
+	}
+	return data
+}
+
+// Gather returns the metrics received since the previous call, starting the listeners on the first call.
+// It returns the following data points, with the tags of the metrics as dimensions
+// - counters (count) with the sum of the values, scaled by the sample rate
+// - gauges with the last value, reported on every call until cwmonitor is restarted
+// - timers, histograms and distributions with the <name>.count (count), scaled by the sample rate,
+// and the <name>.sum, <name>.min, <name>.max and percentiles, e.g. <name>.p90, data points (milliseconds)
+// - sets (count) with the number of unique values
+// or error if the listeners cannot be started.
+func (s *StatsD) Gather() (Data, error) {
+	log.Debug("gathering statsd metrics")
+
+	if err := s.start(); err != nil {
+		return Data{}, err
+	}
+	return s.flush(), nil
+}
+
+// Close stops the listeners and closes the open TCP connections
+func (s *StatsD) Close() error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.started = false
+	err := s.conn.Close()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for conn := range s.tcpConns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatsDLine(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected statsdSample
+	}{
+		{line: "requests:1|c", expected: statsdSample{name: "requests", value: "1", kind: "c", sampleRate: 1}},
+		{line: "requests:2|c|@0.5", expected: statsdSample{name: "requests", value: "2", kind: "c", sampleRate: 0.5}},
+		{line: "queue.size:-3|g", expected: statsdSample{name: "queue.size", value: "-3", kind: "g", sampleRate: 1}},
+		{line: "latency:12.5|ms", expected: statsdSample{name: "latency", value: "12.5", kind: "ms", sampleRate: 1}},
+		{line: "latency:12.5|h", expected: statsdSample{name: "latency", value: "12.5", kind: "ms", sampleRate: 1}},
+		{line: "latency:12.5|d", expected: statsdSample{name: "latency", value: "12.5", kind: "ms", sampleRate: 1}},
+		{line: "users:alice|s", expected: statsdSample{name: "users", value: "alice", kind: "s", sampleRate: 1}},
+		{
+			line: "requests:1|c|@0.1|#route:/api,env:prod,canary",
+			expected: statsdSample{
+				name: "requests", value: "1", kind: "c", sampleRate: 0.1,
+				dimensions: []Dimension{{"env", "prod"}, {"route", "/api"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			s, err := parseStatsDLine(tc.line)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, s)
+		})
+	}
+
+	invalid := []string{"requests", ":1|c", "requests:1", "requests:|c", "requests:1|x", "requests:one|c", "requests:1|c|@2"}
+	for _, line := range invalid {
+		t.Run(line, func(t *testing.T) {
+			_, err := parseStatsDLine(line)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, 9.0, percentile(values, 90))
+	assert.Equal(t, 5.0, percentile(values, 50))
+	assert.Equal(t, 10.0, percentile(values, 99.9))
+	assert.Equal(t, 1.0, percentile(values, 0))
+	assert.Equal(t, 3.0, percentile([]float64{3}, 90))
+}
+
+func TestFormatPercentile(t *testing.T) {
+	assert.Equal(t, "p90", formatPercentile(90))
+	assert.Equal(t, "p99_9", formatPercentile(99.9))
+}
+
+func TestStatsD_Name(t *testing.T) {
+	s := NewStatsD("", "", nil)
+	assert.Equal(t, "statsd", s.Name())
+}
+
+func TestStatsD_Aggregation(t *testing.T) {
+	s := NewStatsD("", "", []float64{50, 90})
+	for _, line := range []string{
+		"requests:1|c|#env:prod",
+		"requests:2|c|@0.5|#env:prod",
+		"requests:1|c|#env:dev",
+		"queue:10|g",
+		"queue:+5|g",
+		"queue:-3|g",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+		"malformed",
+	} {
+		s.handle(line)
+	}
+	for i := 1; i <= 10; i++ {
+		s.add(statsdSample{name: "latency", value: strconv.Itoa(i % 10), kind: "ms", sampleRate: 1})
+	}
+	s.handle("sampled:10|ms|@0.5")
+	s.handle("sampled:20|ms|@0.5")
+
+	data := s.flush()
+	assert.Len(t, data, 16)
+	assert.Equal(t, 5.0, findPoint(data, "requests", Dimension{"env", "prod"}).Value)
+	assert.Equal(t, UnitCount, findPoint(data, "requests", Dimension{"env", "prod"}).Unit)
+	assert.Equal(t, 1.0, findPoint(data, "requests", Dimension{"env", "dev"}).Value)
+	assert.Equal(t, 12.0, findPoint(data, "queue").Value)
+	assert.Equal(t, 2.0, findPoint(data, "users").Value)
+	assert.Equal(t, 10.0, findPoint(data, "latency.count").Value)
+	assert.Equal(t, 45.0, findPoint(data, "latency.sum").Value)
+	assert.Equal(t, 0.0, findPoint(data, "latency.min").Value)
+	assert.Equal(t, 9.0, findPoint(data, "latency.max").Value)
+	assert.Equal(t, 4.0, findPoint(data, "latency.p50").Value)
+	assert.Equal(t, 8.0, findPoint(data, "latency.p90").Value)
+	assert.Equal(t, UnitMilliseconds, findPoint(data, "latency.p90").Unit)
+	// the count of sampled timers estimates the events
+	assert.Equal(t, 4.0, findPoint(data, "sampled.count").Value)
+	assert.Equal(t, 30.0, findPoint(data, "sampled.sum").Value)
+
+	// only gauges are reported again after a flush
+	s.handle("queue:1|g")
+	data = s.flush()
+	assert.Len(t, data, 1)
+	assert.Equal(t, 1.0, findPoint(data, "queue").Value)
+}
+
+// waitForStatsD gathers until all the named data points have been received, keeping the latest
+// point of every metric since gauges are reported again by every call
+func waitForStatsD(t *testing.T, s *StatsD, names ...string) Data {
+	latest := map[string]*Point{}
+	received := func() bool {
+		seen := map[string]bool{}
+		for _, p := range latest {
+			seen[p.Name] = true
+		}
+		for _, name := range names {
+			if !seen[name] {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i < 100 && !received(); i++ {
+		time.Sleep(10 * time.Millisecond)
+		d, err := s.Gather()
+		assert.NoError(t, err)
+		for _, p := range d {
+			latest[p.Name+fmt.Sprint(p.Dimensions)] = p
+		}
+	}
+
+	data := Data{}
+	for _, p := range latest {
+		data = append(data, p)
+	}
+	return data
+}
+
+func TestStatsD_Gather(t *testing.T) {
+	t.Run("udp and tcp listeners", func(t *testing.T) {
+		s := NewStatsD("127.0.0.1:0", "127.0.0.1:0", nil)
+		defer s.Close()
+
+		data, err := s.Gather()
+		assert.NoError(t, err)
+		assert.Len(t, data, 0)
+
+		udp, err := net.Dial("udp", s.conn.LocalAddr().String())
+		assert.NoError(t, err)
+		defer udp.Close()
+		udp.Write([]byte("requests:1|c|#env:prod\nlatency:20|ms"))
+
+		tcp, err := net.Dial("tcp", s.listener.Addr().String())
+		assert.NoError(t, err)
+		defer tcp.Close()
+		tcp.Write([]byte("queue:7|g\n"))
+
+		data = waitForStatsD(t, s, "requests", "latency.count", "queue")
+		assert.Len(t, data, 7)
+		assert.Equal(t, 1.0, findPoint(data, "requests", Dimension{"env", "prod"}).Value)
+		assert.Equal(t, 20.0, findPoint(data, "latency.p90").Value)
+		assert.Equal(t, 7.0, findPoint(data, "queue").Value)
+	})
+
+	t.Run("address already in use", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		s := NewStatsD(conn.LocalAddr().String(), "", nil)
+		_, err = s.Gather()
+		assert.Error(t, err)
+		assert.NoError(t, s.Close())
+	})
+}
+
+func TestStatsD_Close(t *testing.T) {
+	s := NewStatsD("127.0.0.1:0", "127.0.0.1:0", nil)
+	_, err := s.Gather()
+	assert.NoError(t, err)
+
+	// an open tcp connection does not prevent the listener from stopping
+	tcp, err := net.Dial("tcp", s.listener.Addr().String())
+	assert.NoError(t, err)
+	defer tcp.Close()
+	tcp.Write([]byte("queue:7|g\n"))
+	time.Sleep(10 * time.Millisecond)
+
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Close())
+}
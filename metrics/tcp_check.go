@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultTCPCheckTimeout = 5 * time.Second
+
+// TCPCheck checks the connectivity to a list of TCP targets of the form host:port
+type TCPCheck struct {
+	Targets []string
+	Timeout time.Duration
+}
+
+// Name of the TCPCheck metric
+func (c TCPCheck) Name() string {
+	return "tcp-check"
+}
+
+func (c TCPCheck) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultTCPCheckTimeout
+}
+
+// check dials the target and returns the data points describing the outcome
+func (c TCPCheck) check(target string) Data {
+	targetDim, _ := NewDimension("Target", target)
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", target, c.timeout())
+	if err != nil {
+		log.Warnf("tcp check target [%s] failed: %s", target, err)
+		upPoint := NewDataPoint("Up", 0, UnitCount, targetDim)
+		return Data{&upPoint}
+	}
+	connectTime := time.Since(start)
+	conn.Close()
+
+	upPoint := NewDataPoint("Up", 1, UnitCount, targetDim)
+	connectTimePoint := NewDataPoint("ConnectTime", float64(connectTime)/float64(time.Millisecond), UnitMilliseconds, targetDim)
+	return Data{&upPoint, &connectTimePoint}
+}
+
+// Gather checks all the targets in parallel and returns the following data points for every target,
+// with the Target dimension
+// - Up (count) 1 if a connection to the target was established, 0 otherwise
+// - ConnectTime (milliseconds) if a connection was established
+func (c TCPCheck) Gather() (Data, error) {
+	log.Debug("gathering tcp checks")
+
+	results := make([]Data, len(c.Targets))
+	var wg sync.WaitGroup
+	for i, target := range c.Targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i] = c.check(target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	data := Data{}
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTCPCheck_Name(t *testing.T) {
+	c := TCPCheck{}
+	assert.Equal(t, "tcp-check", c.Name())
+}
+
+func TestTCPCheck_Gather(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// a listener closed straight away gives an address nobody is listening on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	openAddress := listener.Addr().String()
+	c := TCPCheck{Targets: []string{openAddress, closedAddress, "invalid"}}
+	data, err := c.Gather()
+
+	assert.NoError(t, err)
+	assert.Len(t, data, 4)
+
+	openDim := Dimension{Name: "Target", Value: openAddress}
+	assert.Equal(t, 1.0, findPoint(data, "Up", openDim).Value)
+	assert.True(t, findPoint(data, "ConnectTime", openDim).Value >= 0)
+	assert.Equal(t, UnitMilliseconds, findPoint(data, "ConnectTime", openDim).Unit)
+
+	closedDim := Dimension{Name: "Target", Value: closedAddress}
+	assert.Equal(t, 0.0, findPoint(data, "Up", closedDim).Value)
+	assert.Nil(t, findPoint(data, "ConnectTime", closedDim))
+
+	assert.Equal(t, 0.0, findPoint(data, "Up", Dimension{Name: "Target", Value: "invalid"}).Value)
+}
@@ -1,6 +1,8 @@
 package monitor
 
 import (
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,13 +16,77 @@ import (
 
 // Config gathers all the necessary data needed by the monitor command
 type Config struct {
-	Namespace   string
-	Interval    time.Duration
-	HostId      string
-	Metrics     string
-	DockerLabel string
-	Once        bool
-	Client      cloudwatchiface.CloudWatchAPI
+	Namespace            string
+	Interval             time.Duration
+	HostId               string
+	Metrics              string
+	Sinks                string
+	DockerLabel          string
+	DockerAggregateLabel string
+	DockerConcurrency    int
+	DockerTimeout        time.Duration
+	DockerStream         bool
+	DockerHosts          []string
+	DockerAPIVersion     string
+	DockerTLSCACert      string
+	DockerTLSCert        string
+	DockerTLSKey         string
+	DockerTLSVerify      bool
+	CgroupRoot           string
+	CgroupRuntimeRoot    string
+	KubeletURL           string
+	KubeletTokenFile     string
+	KubeletInsecure      bool
+	HTTPChecks           []string
+	TCPChecks            []string
+	DNSChecks            []string
+	DNSResolver          string
+	ExecCommands         []string
+	ExecTimeout          time.Duration
+	ExecExitCode         bool
+	NagiosChecks         []string
+	StatsDAddress        string
+	StatsDTCPAddress     string
+	StatsDPercentiles    string
+	PrometheusURLs       []string
+	PrometheusAllow      string
+	PrometheusLabels     string
+	Once                 bool
+	DryRun               bool
+	DryRunFormat         string
+	PrometheusListen     string
+	InfluxDBURL          string
+	InfluxDBDatabase     string
+	InfluxDBUsername     string
+	InfluxDBPassword     string
+	InfluxDBOrg          string
+	InfluxDBBucket       string
+	InfluxDBToken        string
+	InfluxDBBatchSize    int
+	InfluxDBGzip         bool
+	GraphiteAddress      string
+	GraphiteProtocol     string
+	GraphiteTemplate     string
+	EMFOutput            string
+	OTLPURL              string
+	OTLPHeaders          string
+	OTLPResource         string
+	JSONOutput           string
+	JSONMaxSize          int64
+	JSONMaxAge           time.Duration
+	JSONMaxBackups       int
+	DatadogAPIKey        string
+	DatadogURL           string
+	WebhookURL           string
+	WebhookMethod        string
+	WebhookTemplate      string
+	WebhookHeaders       string
+	WebhookUsername      string
+	WebhookPassword      string
+	WebhookToken         string
+	SinkRetries          int
+	SinkBackoff          time.Duration
+	Client               cloudwatchiface.CloudWatchAPI
 }
 
 func (c Config) validate() error {
@@ -38,6 +104,53 @@ func (c Config) validate() error {
 	if c.Metrics == "" {
 		err.Add(errors.New("metrics cannot be empty"))
 	}
+	if c.DryRun && c.DryRunFormat != "" && c.DryRunFormat != "table" && c.DryRunFormat != "json" {
+		err.Add(errors.Errorf("dry run format must be table or json: %s", c.DryRunFormat))
+	}
+
+	for _, s := range c.requestedSinkNames() {
+		if !availableSinks[s] {
+			err.Add(errors.Errorf("unknown sink: %s", s))
+		}
+		if c.DryRun && s != "cloudwatch" {
+			err.Add(errors.Errorf("dry run only prints the cloudwatch requests and cannot be used with the %s sink", s))
+		}
+	}
+
+	for _, s := range c.getSinkNames() {
+		switch s {
+		case "prometheus":
+			if c.PrometheusListen == "" {
+				err.Add(errors.New("prometheus sink requires a listen address"))
+			}
+		case "influxdb":
+			if c.InfluxDBURL == "" {
+				err.Add(errors.New("influxdb sink requires a url"))
+			}
+			if c.InfluxDBDatabase == "" && c.InfluxDBBucket == "" {
+				err.Add(errors.New("influxdb sink requires a database or a bucket"))
+			}
+		case "otlp":
+			if c.OTLPURL == "" {
+				err.Add(errors.New("otlp sink requires a url"))
+			}
+		case "graphite":
+			if c.GraphiteAddress == "" {
+				err.Add(errors.New("graphite sink requires an address"))
+			}
+			if c.GraphiteProtocol != "" && c.GraphiteProtocol != "tcp" && c.GraphiteProtocol != "udp" {
+				err.Add(errors.Errorf("graphite protocol must be tcp or udp: %s", c.GraphiteProtocol))
+			}
+		case "datadog":
+			if c.DatadogAPIKey == "" {
+				err.Add(errors.New("datadog sink requires an api key"))
+			}
+		case "webhook":
+			if c.WebhookURL == "" {
+				err.Add(errors.New("webhook sink requires a url"))
+			}
+		}
+	}
 
 	return err.ErrorOrNil()
 }
@@ -60,9 +173,75 @@ func (c Config) getRequestedMetrics() []metrics.Metric {
 		case "cpu":
 			collectedMetrics = append(collectedMetrics, metrics.CPU{})
 		case "docker-stats":
-			collectedMetrics = append(collectedMetrics, metrics.DockerStat{Label: c.DockerLabel})
+			for _, endpoint := range c.getDockerEndpoints() {
+				stat := metrics.DockerStat{
+					Label:          c.DockerLabel,
+					AggregateLabel: c.DockerAggregateLabel,
+					Concurrency:    c.DockerConcurrency,
+					Timeout:        c.DockerTimeout,
+					Deadline:       c.Interval,
+				}
+				stat.Endpoint = endpoint
+				if c.DockerStream {
+					collectedMetrics = append(collectedMetrics, metrics.NewDockerStatStream(stat))
+				} else {
+					collectedMetrics = append(collectedMetrics, &stat)
+				}
+			}
 		case "docker-health":
-			collectedMetrics = append(collectedMetrics, metrics.DockerHealth{Label: c.DockerLabel})
+			for _, endpoint := range c.getDockerEndpoints() {
+				health := metrics.DockerHealth{Label: c.DockerLabel, AggregateLabel: c.DockerAggregateLabel}
+				health.Endpoint = endpoint
+				collectedMetrics = append(collectedMetrics, &health)
+			}
+		case "docker-df":
+			for _, endpoint := range c.getDockerEndpoints() {
+				df := metrics.DockerDiskUsage{Timeout: c.Interval}
+				df.Endpoint = endpoint
+				collectedMetrics = append(collectedMetrics, &df)
+			}
+		case "docker-swarm":
+			for _, endpoint := range c.getDockerEndpoints() {
+				swarm := metrics.DockerSwarm{}
+				swarm.Endpoint = endpoint
+				collectedMetrics = append(collectedMetrics, &swarm)
+			}
+		case "cgroup":
+			cgroup := metrics.NewCgroup(c.CgroupRoot)
+			cgroup.RuntimeRoot = c.CgroupRuntimeRoot
+			collectedMetrics = append(collectedMetrics, cgroup)
+		case "kubelet":
+			collectedMetrics = append(collectedMetrics, metrics.Kubelet{
+				URL:                c.KubeletURL,
+				TokenFile:          c.KubeletTokenFile,
+				InsecureSkipVerify: c.KubeletInsecure,
+			})
+		case "http-check":
+			if targets := c.getHTTPTargets(); len(targets) > 0 {
+				collectedMetrics = append(collectedMetrics, metrics.HTTPCheck{Targets: targets})
+			} else {
+				log.Warn("no valid target for the http-check metric")
+			}
+		case "tcp-check":
+			collectedMetrics = append(collectedMetrics, metrics.TCPCheck{Targets: c.TCPChecks})
+		case "dns-check":
+			collectedMetrics = append(collectedMetrics, metrics.DNSCheck{Names: c.DNSChecks, Resolver: c.DNSResolver})
+		case "exec":
+			collectedMetrics = append(collectedMetrics, metrics.Exec{
+				Commands:       c.ExecCommands,
+				Timeout:        c.ExecTimeout,
+				ReportExitCode: c.ExecExitCode,
+			})
+		case "nagios":
+			collectedMetrics = append(collectedMetrics, metrics.Nagios{Checks: c.NagiosChecks, Timeout: c.ExecTimeout})
+		case "statsd":
+			collectedMetrics = append(collectedMetrics, metrics.NewStatsD(c.StatsDAddress, c.StatsDTCPAddress, c.getStatsDPercentiles()))
+		case "prometheus":
+			collectedMetrics = append(collectedMetrics, metrics.NewPrometheusScrape(
+				c.PrometheusURLs,
+				splitList(c.PrometheusAllow),
+				c.getPrometheusLabels(),
+			))
 		case "":
 			continue
 		default:
@@ -73,6 +252,209 @@ func (c Config) getRequestedMetrics() []metrics.Metric {
 	return collectedMetrics
 }
 
+// availableSinks are the names of the sinks the data can be published to
+var availableSinks = map[string]bool{
+	"cloudwatch": true,
+	"prometheus": true,
+	"influxdb":   true,
+	"graphite":   true,
+	"emf":        true,
+	"otlp":       true,
+	"json":       true,
+	"datadog":    true,
+	"webhook":    true,
+}
+
+// requestedSinkNames returns the names of the sinks given with --sinks without duplicates
+func (c Config) requestedSinkNames() []string {
+	requested := splitList(c.Sinks)
+	names := make([]string, 0, len(requested))
+	seen := map[string]bool{}
+	for _, name := range requested {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// getSinkNames returns the names of the requested sinks without duplicates, cloudwatch if none was requested.
+// Only the cloudwatch sink is used in dry run mode.
+func (c Config) getSinkNames() []string {
+	if c.DryRun {
+		return []string{"cloudwatch"}
+	}
+
+	names := c.requestedSinkNames()
+	if len(names) == 0 {
+		return []string{"cloudwatch"}
+	}
+	return names
+}
+
+// getRequestedSinks returns the requested sinks or error if a sink cannot be started,
+// in which case the sinks already started are closed
+func (c Config) getRequestedSinks() ([]Sink, error) {
+	sinks := []Sink{}
+	for _, name := range c.getSinkNames() {
+		switch name {
+		case "cloudwatch":
+			client := c.Client
+			if c.DryRun {
+				client = NewDryRunClient(os.Stdout, c.DryRunFormat)
+			}
+			sinks = append(sinks, CloudWatchSink{Namespace: c.Namespace, Client: client})
+		case "prometheus":
+			exporter := NewPrometheusExporter(c.Namespace)
+			if _, err := exporter.Serve(c.PrometheusListen); err != nil {
+				closeSinks(sinks)
+				return nil, err
+			}
+			sinks = append(sinks, exporter)
+		case "influxdb":
+			sinks = append(sinks, InfluxDBSink{
+				URL:       c.InfluxDBURL,
+				Database:  c.InfluxDBDatabase,
+				Username:  c.InfluxDBUsername,
+				Password:  c.InfluxDBPassword,
+				Org:       c.InfluxDBOrg,
+				Bucket:    c.InfluxDBBucket,
+				Token:     c.InfluxDBToken,
+				BatchSize: c.InfluxDBBatchSize,
+				Gzip:      c.InfluxDBGzip,
+			})
+		case "graphite":
+			sinks = append(sinks, NewGraphiteSink(c.GraphiteAddress, c.GraphiteProtocol, c.GraphiteTemplate, c.Namespace))
+		case "emf":
+			sinks = append(sinks, NewEMFSink(c.Namespace, c.EMFOutput))
+		case "json":
+			sinks = append(sinks, NewJSONSink(c.JSONOutput, c.JSONMaxSize, c.JSONMaxAge, c.JSONMaxBackups))
+		case "otlp":
+			sinks = append(sinks, NewOTLPSink(c.OTLPURL, splitKeyValues(c.OTLPHeaders), splitKeyValues(c.OTLPResource)))
+		case "datadog":
+			sinks = append(sinks, DatadogSink{
+				httpRetry: c.getHTTPRetry(),
+				URL:       c.DatadogURL,
+				APIKey:    c.DatadogAPIKey,
+				Namespace: c.Namespace,
+			})
+		case "webhook":
+			webhook, err := NewWebhookSink(c.WebhookURL, c.WebhookTemplate, c.Namespace)
+			if err != nil {
+				closeSinks(sinks)
+				return nil, err
+			}
+			webhook.httpRetry = c.getHTTPRetry()
+			webhook.Method = c.WebhookMethod
+			webhook.Headers = splitKeyValues(c.WebhookHeaders)
+			webhook.Username = c.WebhookUsername
+			webhook.Password = c.WebhookPassword
+			webhook.Token = c.WebhookToken
+			sinks = append(sinks, webhook)
+		default:
+			log.Warnf("unknown sink: %s", name)
+		}
+	}
+	return sinks, nil
+}
+
+// getHTTPRetry returns the retry policy of the sinks sending the data over HTTP
+func (c Config) getHTTPRetry() httpRetry {
+	return httpRetry{Retries: c.SinkRetries, Backoff: c.SinkBackoff}
+}
+
+// getDockerEndpoints returns an endpoint for every requested docker host sharing the same API version and
+// TLS configuration or a single endpoint configured from the environment if no host was requested
+func (c Config) getDockerEndpoints() []metrics.DockerEndpoint {
+	endpoint := metrics.DockerEndpoint{
+		APIVersion: c.DockerAPIVersion,
+		TLSCACert:  c.DockerTLSCACert,
+		TLSCert:    c.DockerTLSCert,
+		TLSKey:     c.DockerTLSKey,
+		TLSVerify:  c.DockerTLSVerify,
+	}
+
+	endpoints := make([]metrics.DockerEndpoint, 0, len(c.DockerHosts))
+	for _, host := range c.DockerHosts {
+		if host = strings.TrimSpace(host); host != "" {
+			e := endpoint
+			e.Host = host
+			endpoints = append(endpoints, e)
+		}
+	}
+	if len(endpoints) == 0 {
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// getHTTPTargets returns the valid targets for the http-check metric, invalid targets are logged and skipped
+func (c Config) getHTTPTargets() []metrics.HTTPTarget {
+	targets := make([]metrics.HTTPTarget, 0, len(c.HTTPChecks))
+	for _, spec := range c.HTTPChecks {
+		target, err := metrics.ParseHTTPTarget(spec)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// getStatsDPercentiles returns the valid percentiles reported for statsd timers, invalid percentiles are logged and skipped
+func (c Config) getStatsDPercentiles() []float64 {
+	percentiles := []float64{}
+	for _, p := range splitList(c.StatsDPercentiles) {
+		value, err := strconv.ParseFloat(p, 64)
+		if err != nil || value <= 0 || value > 100 {
+			log.Warnf("invalid statsd percentile: %s", p)
+			continue
+		}
+		percentiles = append(percentiles, value)
+	}
+	return percentiles
+}
+
+// getPrometheusLabels returns the mapping of the prometheus labels reported as dimensions given as
+// label[=Dimension],... where the dimension is named after the label if not given
+func (c Config) getPrometheusLabels() map[string]string {
+	labels := map[string]string{}
+	for _, l := range splitList(c.PrometheusLabels) {
+		kv := strings.SplitN(l, "=", 2)
+		labels[kv[0]] = ""
+		if len(kv) == 2 {
+			labels[kv[0]] = strings.TrimSpace(kv[1])
+		}
+	}
+	return labels
+}
+
+// splitList splits a comma separated list skipping blank values
+func splitList(list string) []string {
+	values := []string{}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// splitKeyValues splits a comma separated list of key=value pairs skipping the pairs without a key
+func splitKeyValues(list string) map[string]string {
+	values := map[string]string{}
+	for _, kv := range splitList(list) {
+		pair := strings.SplitN(kv, "=", 2)
+		if key := strings.TrimSpace(pair[0]); key != "" && len(pair) == 2 {
+			values[key] = strings.TrimSpace(pair[1])
+		}
+	}
+	return values
+}
+
 func (c Config) getExtraDimensions() []metrics.Dimension {
 	extraDimensions, _ := metrics.MapToDimensions(map[string]string{"Host": c.HostId})
 	return extraDimensions
@@ -88,7 +470,176 @@ func (c Config) logConfig() {
 	log.Infof("  Interval:  %s", c.Interval)
 	log.Infof("  Namespace: %s", c.Namespace)
 	log.Infof("  Metrics:   %s", c.Metrics)
+	log.Infof("  Sinks:     %s", strings.Join(c.getSinkNames(), ","))
+	if c.DryRun {
+		log.Infof("  DryRun: %t", c.DryRun)
+	}
+	if c.DryRunFormat != "" {
+		log.Infof("  DryRunFormat: %s", c.DryRunFormat)
+	}
+	if c.PrometheusListen != "" {
+		log.Infof("  PrometheusListen: %s", c.PrometheusListen)
+	}
+	if c.InfluxDBURL != "" {
+		log.Infof("  InfluxDBURL: %s", c.InfluxDBURL)
+	}
+	if c.InfluxDBDatabase != "" {
+		log.Infof("  InfluxDBDatabase: %s", c.InfluxDBDatabase)
+	}
+	if c.InfluxDBUsername != "" {
+		log.Infof("  InfluxDBUsername: %s", c.InfluxDBUsername)
+	}
+	if c.InfluxDBOrg != "" {
+		log.Infof("  InfluxDBOrg: %s", c.InfluxDBOrg)
+	}
+	if c.InfluxDBBucket != "" {
+		log.Infof("  InfluxDBBucket: %s", c.InfluxDBBucket)
+	}
+	if c.InfluxDBBatchSize != 0 {
+		log.Infof("  InfluxDBBatchSize: %d", c.InfluxDBBatchSize)
+	}
+	if c.InfluxDBGzip {
+		log.Infof("  InfluxDBGzip: %t", c.InfluxDBGzip)
+	}
+	if c.GraphiteAddress != "" {
+		log.Infof("  GraphiteAddress: %s", c.GraphiteAddress)
+	}
+	if c.GraphiteProtocol != "" {
+		log.Infof("  GraphiteProtocol: %s", c.GraphiteProtocol)
+	}
+	if c.GraphiteTemplate != "" {
+		log.Infof("  GraphiteTemplate: %s", c.GraphiteTemplate)
+	}
+	if c.EMFOutput != "" {
+		log.Infof("  EMFOutput: %s", c.EMFOutput)
+	}
+	if c.OTLPURL != "" {
+		log.Infof("  OTLPURL: %s", c.OTLPURL)
+	}
+	if c.OTLPResource != "" {
+		log.Infof("  OTLPResource: %s", c.OTLPResource)
+	}
+	if c.JSONOutput != "" {
+		log.Infof("  JSONOutput: %s", c.JSONOutput)
+	}
+	if c.JSONMaxSize != 0 {
+		log.Infof("  JSONMaxSize: %d", c.JSONMaxSize)
+	}
+	if c.JSONMaxAge != time.Duration(0) {
+		log.Infof("  JSONMaxAge: %s", c.JSONMaxAge)
+	}
+	if c.JSONMaxBackups != 0 {
+		log.Infof("  JSONMaxBackups: %d", c.JSONMaxBackups)
+	}
+	if c.DatadogURL != "" {
+		log.Infof("  DatadogURL: %s", c.DatadogURL)
+	}
+	if c.WebhookURL != "" {
+		log.Infof("  WebhookURL: %s", c.WebhookURL)
+	}
+	if c.WebhookMethod != "" {
+		log.Infof("  WebhookMethod: %s", c.WebhookMethod)
+	}
+	if c.WebhookTemplate != "" {
+		log.Infof("  WebhookTemplate: %s", c.WebhookTemplate)
+	}
+	if c.WebhookUsername != "" {
+		log.Infof("  WebhookUsername: %s", c.WebhookUsername)
+	}
+	if c.SinkRetries != 0 {
+		log.Infof("  SinkRetries: %d", c.SinkRetries)
+	}
+	if c.SinkBackoff != time.Duration(0) {
+		log.Infof("  SinkBackoff: %s", c.SinkBackoff)
+	}
 	if c.DockerLabel != "" {
 		log.Infof("  Metrics.DockerLabel: %s", c.DockerLabel)
 	}
+	if c.DockerAggregateLabel != "" {
+		log.Infof("  Metrics.DockerAggregateLabel: %s", c.DockerAggregateLabel)
+	}
+	if c.DockerConcurrency != 0 {
+		log.Infof("  Metrics.DockerConcurrency: %d", c.DockerConcurrency)
+	}
+	if c.DockerTimeout != time.Duration(0) {
+		log.Infof("  Metrics.DockerTimeout: %s", c.DockerTimeout)
+	}
+	if c.DockerStream {
+		log.Infof("  Metrics.DockerStream: %t", c.DockerStream)
+	}
+	if len(c.DockerHosts) > 0 {
+		log.Infof("  Metrics.DockerHosts: %s", strings.Join(c.DockerHosts, ","))
+	}
+	if c.DockerAPIVersion != "" {
+		log.Infof("  Metrics.DockerAPIVersion: %s", c.DockerAPIVersion)
+	}
+	if c.DockerTLSCACert != "" {
+		log.Infof("  Metrics.DockerTLSCACert: %s", c.DockerTLSCACert)
+	}
+	if c.DockerTLSCert != "" {
+		log.Infof("  Metrics.DockerTLSCert: %s", c.DockerTLSCert)
+	}
+	if c.DockerTLSKey != "" {
+		log.Infof("  Metrics.DockerTLSKey: %s", c.DockerTLSKey)
+	}
+	if c.DockerTLSVerify {
+		log.Infof("  Metrics.DockerTLSVerify: %t", c.DockerTLSVerify)
+	}
+	if c.CgroupRoot != "" {
+		log.Infof("  Metrics.CgroupRoot: %s", c.CgroupRoot)
+	}
+	if c.CgroupRuntimeRoot != "" {
+		log.Infof("  Metrics.CgroupRuntimeRoot: %s", c.CgroupRuntimeRoot)
+	}
+	if c.KubeletURL != "" {
+		log.Infof("  Metrics.KubeletURL: %s", c.KubeletURL)
+	}
+	if c.KubeletTokenFile != "" {
+		log.Infof("  Metrics.KubeletTokenFile: %s", c.KubeletTokenFile)
+	}
+	if c.KubeletInsecure {
+		log.Infof("  Metrics.KubeletInsecure: %t", c.KubeletInsecure)
+	}
+	if len(c.HTTPChecks) > 0 {
+		log.Infof("  Metrics.HTTPChecks: %s", strings.Join(c.HTTPChecks, ","))
+	}
+	if len(c.TCPChecks) > 0 {
+		log.Infof("  Metrics.TCPChecks: %s", strings.Join(c.TCPChecks, ","))
+	}
+	if len(c.DNSChecks) > 0 {
+		log.Infof("  Metrics.DNSChecks: %s", strings.Join(c.DNSChecks, ","))
+	}
+	if c.DNSResolver != "" {
+		log.Infof("  Metrics.DNSResolver: %s", c.DNSResolver)
+	}
+	if len(c.ExecCommands) > 0 {
+		log.Infof("  Metrics.ExecCommands: %s", strings.Join(c.ExecCommands, ","))
+	}
+	if c.ExecTimeout != time.Duration(0) {
+		log.Infof("  Metrics.ExecTimeout: %s", c.ExecTimeout)
+	}
+	if c.ExecExitCode {
+		log.Infof("  Metrics.ExecExitCode: %t", c.ExecExitCode)
+	}
+	if len(c.NagiosChecks) > 0 {
+		log.Infof("  Metrics.NagiosChecks: %s", strings.Join(c.NagiosChecks, ","))
+	}
+	if c.StatsDAddress != "" {
+		log.Infof("  Metrics.StatsDAddress: %s", c.StatsDAddress)
+	}
+	if c.StatsDTCPAddress != "" {
+		log.Infof("  Metrics.StatsDTCPAddress: %s", c.StatsDTCPAddress)
+	}
+	if c.StatsDPercentiles != "" {
+		log.Infof("  Metrics.StatsDPercentiles: %s", c.StatsDPercentiles)
+	}
+	if len(c.PrometheusURLs) > 0 {
+		log.Infof("  Metrics.PrometheusURLs: %s", strings.Join(c.PrometheusURLs, ","))
+	}
+	if c.PrometheusAllow != "" {
+		log.Infof("  Metrics.PrometheusAllow: %s", c.PrometheusAllow)
+	}
+	if c.PrometheusLabels != "" {
+		log.Infof("  Metrics.PrometheusLabels: %s", c.PrometheusLabels)
+	}
 }
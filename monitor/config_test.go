@@ -40,6 +40,140 @@ func TestConfig_validate(t *testing.T) {
 		assert.Contains(t, err.Error(), "metrics")
 	})
 
+	t.Run("validates prometheus sink", func(t *testing.T) {
+		c := Config{
+			Namespace: "namespace",
+			Interval:  time.Minute,
+			HostId:    "id",
+			Metrics:   "cpu,memory",
+			Sinks:     "cloudwatch,prometheus",
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "prometheus")
+	})
+
+	t.Run("validates influxdb sink", func(t *testing.T) {
+		c := Config{
+			Namespace: "namespace",
+			Interval:  time.Minute,
+			HostId:    "id",
+			Metrics:   "cpu,memory",
+			Sinks:     "influxdb",
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "url")
+		assert.Contains(t, err.Error(), "database or a bucket")
+
+		c.InfluxDBURL = "http://localhost:8086"
+		c.InfluxDBBucket = "bucket"
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("validates dry run format", func(t *testing.T) {
+		c := Config{
+			Namespace:    "namespace",
+			Interval:     time.Minute,
+			HostId:       "id",
+			Metrics:      "cpu,memory",
+			DryRun:       true,
+			DryRunFormat: "yaml",
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "table or json")
+
+		c.DryRunFormat = "json"
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("validates sink names", func(t *testing.T) {
+		c := Config{
+			Namespace: "namespace",
+			Interval:  time.Minute,
+			HostId:    "id",
+			Metrics:   "cpu,memory",
+			Sinks:     "cloudwatch,statsd,statsd",
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Equal(t, 1, strings.Count(err.Error(), "unknown sink: statsd"))
+
+		c.Sinks = "cloudwatch,emf"
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("validates sinks in dry run", func(t *testing.T) {
+		c := Config{
+			Namespace: "namespace",
+			Interval:  time.Minute,
+			HostId:    "id",
+			Metrics:   "cpu,memory",
+			Sinks:     "cloudwatch,emf",
+			DryRun:    true,
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be used with the emf sink")
+
+		c.Sinks = "cloudwatch"
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("validates otlp sink", func(t *testing.T) {
+		c := Config{
+			Namespace: "namespace",
+			Interval:  time.Minute,
+			HostId:    "id",
+			Metrics:   "cpu,memory",
+			Sinks:     "otlp",
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "otlp sink requires a url")
+
+		c.OTLPURL = "http://localhost:4318"
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("validates graphite sink", func(t *testing.T) {
+		c := Config{
+			Namespace:        "namespace",
+			Interval:         time.Minute,
+			HostId:           "id",
+			Metrics:          "cpu,memory",
+			Sinks:            "graphite",
+			GraphiteProtocol: "http",
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "address")
+		assert.Contains(t, err.Error(), "tcp or udp")
+
+		c.GraphiteAddress = "localhost:2003"
+		c.GraphiteProtocol = "udp"
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("validates datadog and webhook sinks", func(t *testing.T) {
+		c := Config{
+			Namespace: "namespace",
+			Interval:  time.Minute,
+			HostId:    "id",
+			Metrics:   "cpu,memory",
+			Sinks:     "datadog,webhook",
+		}
+		err := c.validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "datadog sink requires an api key")
+		assert.Contains(t, err.Error(), "webhook sink requires a url")
+
+		c.DatadogAPIKey = "key"
+		c.WebhookURL = "http://localhost:8080"
+		assert.NoError(t, c.validate())
+	})
+
 	t.Run("valid", func(t *testing.T) {
 		c := Config{
 			Namespace: "namespace",
@@ -62,8 +196,16 @@ func TestConfig_getRequestedMetrics(t *testing.T) {
 		{input: "swap", expected: []metrics.Metric{metrics.Swap{}}},
 		{input: "cpu", expected: []metrics.Metric{metrics.CPU{}}},
 		{input: "disk", expected: []metrics.Metric{metrics.Disk{}}},
-		{input: "docker-stats", expected: []metrics.Metric{metrics.DockerStat{}}},
-		{input: "docker-health", expected: []metrics.Metric{metrics.DockerHealth{}}},
+		{input: "docker-stats", expected: []metrics.Metric{&metrics.DockerStat{}}},
+		{input: "docker-health", expected: []metrics.Metric{&metrics.DockerHealth{}}},
+		{input: "docker-df", expected: []metrics.Metric{&metrics.DockerDiskUsage{}}},
+		{input: "docker-swarm", expected: []metrics.Metric{&metrics.DockerSwarm{}}},
+		{input: "cgroup", expected: []metrics.Metric{metrics.NewCgroup("")}},
+		{input: "kubelet", expected: []metrics.Metric{metrics.Kubelet{}}},
+		{input: "tcp-check", expected: []metrics.Metric{metrics.TCPCheck{}}},
+		{input: "dns-check", expected: []metrics.Metric{metrics.DNSCheck{}}},
+		{input: "exec", expected: []metrics.Metric{metrics.Exec{}}},
+		{input: "nagios", expected: []metrics.Metric{metrics.Nagios{}}},
 		{input: "cpu,memory", expected: []metrics.Metric{metrics.CPU{}, metrics.Memory{}}},
 		{input: "cpu,foo", expected: []metrics.Metric{metrics.CPU{}}},
 		{input: ",", expected: []metrics.Metric{}},
@@ -79,6 +221,216 @@ func TestConfig_getRequestedMetrics(t *testing.T) {
 	}
 }
 
+func TestConfig_getRequestedMetrics_dockerStream(t *testing.T) {
+	c := Config{Metrics: "docker-stats", DockerLabel: "label", DockerStream: true}
+	output := c.getRequestedMetrics()
+
+	assert.Len(t, output, 1)
+	assert.IsType(t, &metrics.DockerStatStream{}, output[0])
+	assert.Equal(t, "label", output[0].(*metrics.DockerStatStream).Label)
+}
+
+func TestConfig_getRequestedMetrics_dockerHosts(t *testing.T) {
+	c := Config{
+		Metrics:         "docker-health,docker-df",
+		DockerHosts:     []string{"unix:///run/podman/podman.sock", " tcp://10.0.0.1:2376", ""},
+		DockerTLSCACert: "ca.pem",
+	}
+	output := c.getRequestedMetrics()
+
+	assert.Len(t, output, 4)
+	hosts := map[string]int{}
+	for _, m := range output {
+		var endpoint metrics.DockerEndpoint
+		switch v := m.(type) {
+		case *metrics.DockerHealth:
+			endpoint = v.Endpoint
+		case *metrics.DockerDiskUsage:
+			endpoint = v.Endpoint
+		}
+		assert.Equal(t, "ca.pem", endpoint.TLSCACert)
+		hosts[endpoint.Host]++
+	}
+	assert.Equal(t, map[string]int{"unix:///run/podman/podman.sock": 2, "tcp://10.0.0.1:2376": 2}, hosts)
+}
+
+func TestConfig_getDockerEndpoints(t *testing.T) {
+	t.Run("environment endpoint if no host is requested", func(t *testing.T) {
+		c := Config{DockerAPIVersion: "1.24", DockerTLSVerify: true}
+		assert.Equal(t, []metrics.DockerEndpoint{{APIVersion: "1.24", TLSVerify: true}}, c.getDockerEndpoints())
+	})
+
+	t.Run("an endpoint for every requested host", func(t *testing.T) {
+		c := Config{DockerHosts: []string{"tcp://a:2376", "tcp://b:2376"}, DockerTLSCert: "cert.pem", DockerTLSKey: "key.pem"}
+		assert.Equal(t, []metrics.DockerEndpoint{
+			{Host: "tcp://a:2376", TLSCert: "cert.pem", TLSKey: "key.pem"},
+			{Host: "tcp://b:2376", TLSCert: "cert.pem", TLSKey: "key.pem"},
+		}, c.getDockerEndpoints())
+	})
+}
+
+func TestConfig_getRequestedMetrics_httpCheck(t *testing.T) {
+	t.Run("valid targets", func(t *testing.T) {
+		c := Config{Metrics: "http-check", HTTPChecks: []string{"http://localhost/health", "localhost", "https://example.com;status=204"}}
+		output := c.getRequestedMetrics()
+
+		assert.Len(t, output, 1)
+		targets := output[0].(metrics.HTTPCheck).Targets
+		assert.Len(t, targets, 2)
+		assert.Equal(t, "http://localhost/health", targets[0].URL)
+		assert.Equal(t, "https://example.com", targets[1].URL)
+		assert.Equal(t, []int{204}, targets[1].ExpectedStatus)
+	})
+
+	t.Run("no valid targets", func(t *testing.T) {
+		c := Config{Metrics: "http-check", HTTPChecks: []string{"localhost"}}
+		assert.Len(t, c.getRequestedMetrics(), 0)
+	})
+}
+
+func TestConfig_getRequestedMetrics_statsd(t *testing.T) {
+	c := Config{Metrics: "statsd", StatsDAddress: ":9125", StatsDTCPAddress: ":9126", StatsDPercentiles: "90,99.9"}
+	output := c.getRequestedMetrics()
+
+	assert.Len(t, output, 1)
+	assert.IsType(t, &metrics.StatsD{}, output[0])
+	statsd := output[0].(*metrics.StatsD)
+	assert.Equal(t, ":9125", statsd.Address)
+	assert.Equal(t, ":9126", statsd.TCPAddress)
+	assert.Equal(t, []float64{90, 99.9}, statsd.Percentiles)
+}
+
+func TestConfig_getStatsDPercentiles(t *testing.T) {
+	assert.Equal(t, []float64{}, Config{}.getStatsDPercentiles())
+	assert.Equal(t, []float64{50, 99}, Config{StatsDPercentiles: "50, 99"}.getStatsDPercentiles())
+	assert.Equal(t, []float64{95}, Config{StatsDPercentiles: "p90,0,101,95,"}.getStatsDPercentiles())
+}
+
+func TestConfig_getRequestedMetrics_prometheus(t *testing.T) {
+	c := Config{
+		Metrics:          "prometheus",
+		PrometheusURLs:   []string{"http://localhost:9090/metrics"},
+		PrometheusAllow:  "http_*, process_*",
+		PrometheusLabels: "method=Method,code",
+	}
+	output := c.getRequestedMetrics()
+
+	assert.Len(t, output, 1)
+	assert.IsType(t, &metrics.PrometheusScrape{}, output[0])
+	p := output[0].(*metrics.PrometheusScrape)
+	assert.Equal(t, []string{"http://localhost:9090/metrics"}, p.URLs)
+	assert.Equal(t, []string{"http_*", "process_*"}, p.Allow)
+	assert.Equal(t, map[string]string{"method": "Method", "code": ""}, p.Labels)
+}
+
+func TestConfig_getSinkNames(t *testing.T) {
+	assert.Equal(t, []string{"cloudwatch"}, Config{}.getSinkNames())
+	assert.Equal(t, []string{"cloudwatch"}, Config{Sinks: " , "}.getSinkNames())
+	assert.Equal(t, []string{"prometheus", "cloudwatch"}, Config{Sinks: "prometheus, cloudwatch,prometheus"}.getSinkNames())
+	// the listen address only configures the prometheus sink
+	assert.Equal(t, []string{"cloudwatch"}, Config{PrometheusListen: ":9273"}.getSinkNames())
+}
+
+func TestConfig_getRequestedSinks(t *testing.T) {
+	t.Run("valid sinks", func(t *testing.T) {
+		mockClient := new(mockCloudWatchClient)
+		c := Config{
+			Namespace:        "namespace",
+			Sinks:            "cloudwatch,unknown,prometheus,influxdb,graphite,emf,otlp,json,datadog,webhook",
+			PrometheusListen: "127.0.0.1:0",
+			InfluxDBURL:      "http://localhost:8086",
+			InfluxDBDatabase: "telegraf",
+			InfluxDBGzip:     true,
+			GraphiteAddress:  "localhost:2003",
+			EMFOutput:        "udp://127.0.0.1:25888",
+			OTLPURL:          "http://localhost:4318",
+			OTLPHeaders:      "Authorization=Bearer token",
+			OTLPResource:     "deployment.environment=production",
+			JSONOutput:       "metrics.json",
+			JSONMaxSize:      1 << 20,
+			JSONMaxAge:       time.Hour,
+			JSONMaxBackups:   3,
+			DatadogAPIKey:    "key",
+			WebhookURL:       "http://localhost:8080",
+			WebhookMethod:    "PUT",
+			WebhookHeaders:   "X-Source=cwmonitor",
+			WebhookToken:     "token",
+			SinkRetries:      2,
+			SinkBackoff:      time.Second,
+			Client:           mockClient,
+		}
+		sinks, err := c.getRequestedSinks()
+		defer closeSinks(sinks)
+
+		assert.NoError(t, err)
+		assert.Len(t, sinks, 9)
+		assert.Equal(t, CloudWatchSink{Namespace: "namespace", Client: mockClient}, sinks[0])
+		assert.IsType(t, &PrometheusExporter{}, sinks[1])
+		assert.Equal(t, InfluxDBSink{URL: "http://localhost:8086", Database: "telegraf", Gzip: true}, sinks[2])
+		assert.Equal(t, NewGraphiteSink("localhost:2003", "", "", "namespace"), sinks[3])
+		assert.Equal(t, NewEMFSink("namespace", "udp://127.0.0.1:25888"), sinks[4])
+		assert.Equal(t, NewOTLPSink(
+			"http://localhost:4318",
+			map[string]string{"Authorization": "Bearer token"},
+			map[string]string{"deployment.environment": "production"},
+		), sinks[5])
+		assert.IsType(t, &JSONSink{}, sinks[6])
+		json := sinks[6].(*JSONSink)
+		assert.Equal(t, "metrics.json", json.Output)
+		assert.Equal(t, int64(1<<20), json.MaxSize)
+		assert.Equal(t, time.Hour, json.MaxAge)
+		assert.Equal(t, 3, json.MaxBackups)
+		assert.Equal(t, DatadogSink{
+			httpRetry: httpRetry{Retries: 2, Backoff: time.Second},
+			APIKey:    "key",
+			Namespace: "namespace",
+		}, sinks[7])
+		assert.IsType(t, &WebhookSink{}, sinks[8])
+		webhook := sinks[8].(*WebhookSink)
+		assert.Equal(t, "http://localhost:8080", webhook.URL)
+		assert.Equal(t, "PUT", webhook.Method)
+		assert.Equal(t, map[string]string{"X-Source": "cwmonitor"}, webhook.Headers)
+		assert.Equal(t, "token", webhook.Token)
+		assert.Equal(t, httpRetry{Retries: 2, Backoff: time.Second}, webhook.httpRetry)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		c := Config{Namespace: "namespace", Sinks: "cloudwatch", DryRun: true, DryRunFormat: "json", Client: new(mockCloudWatchClient)}
+		sinks, err := c.getRequestedSinks()
+
+		assert.NoError(t, err)
+		assert.Len(t, sinks, 1)
+		assert.IsType(t, CloudWatchSink{}, sinks[0])
+		assert.IsType(t, &DryRunClient{}, sinks[0].(CloudWatchSink).Client)
+		assert.Equal(t, "json", sinks[0].(CloudWatchSink).Client.(*DryRunClient).Format)
+	})
+
+	t.Run("invalid webhook template", func(t *testing.T) {
+		c := Config{Sinks: "json,webhook", WebhookURL: "http://localhost:8080", WebhookTemplate: "{{.Points"}
+		_, err := c.getRequestedSinks()
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid webhook template")
+	})
+
+	t.Run("failing sink", func(t *testing.T) {
+		c := Config{Sinks: "prometheus", PrometheusListen: "invalid:address:1"}
+		_, err := c.getRequestedSinks()
+
+		assert.Error(t, err)
+	})
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{}, splitList(""))
+	assert.Equal(t, []string{"a", "b"}, splitList(" a,,b , "))
+}
+
+func TestSplitKeyValues(t *testing.T) {
+	assert.Equal(t, map[string]string{}, splitKeyValues(""))
+	assert.Equal(t, map[string]string{"a": "1", "b": "x=y", "c": ""}, splitKeyValues("a=1, b = x=y,c=,d,=2"))
+}
+
 func TestConfig_getExtraDimensions(t *testing.T) {
 	c := Config{HostId: "id"}
 	dim := c.getExtraDimensions()
@@ -91,11 +443,12 @@ func TestConfig_getExtraDimensions(t *testing.T) {
 func TestConfig_logConfig(t *testing.T) {
 	hook := test.NewGlobal()
 	Config{
-		Namespace:   "namespace",
-		Interval:    time.Second * 30,
-		HostId:      "id",
-		Metrics:     "cpu,memory",
-		DockerLabel: "a_label",
+		Namespace:            "namespace",
+		Interval:             time.Second * 30,
+		HostId:               "id",
+		Metrics:              "cpu,memory",
+		DockerLabel:          "a_label",
+		DockerAggregateLabel: "an_aggregate_label",
 	}.logConfig()
 
 	messages := make([]string, len(hook.AllEntries()))
@@ -109,4 +462,5 @@ func TestConfig_logConfig(t *testing.T) {
 	assert.Contains(t, logOutput, "id")
 	assert.Contains(t, logOutput, "cpu,memory")
 	assert.Contains(t, logOutput, "a_label")
+	assert.Contains(t, logOutput, "an_aggregate_label")
 }
@@ -0,0 +1,127 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/dedalusj/cwmonitor/util"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDatadogURL       = "https://api.datadoghq.com"
+	defaultDatadogBatchSize = 500
+	datadogSeriesPath       = "/api/v1/series"
+)
+
+// datadogSeries is a series of the Datadog series API
+type datadogSeries struct {
+	Metric string       `json:"metric"`
+	Points [][2]float64 `json:"points"`
+	Type   string       `json:"type"`
+	Host   string       `json:"host,omitempty"`
+	Tags   []string     `json:"tags,omitempty"`
+}
+
+// datadogRequest is the body of a request to the Datadog series API
+type datadogRequest struct {
+	Series []datadogSeries `json:"series"`
+}
+
+// DatadogSink posts the data to the series API of Datadog at URL, e.g. https://api.datadoghq.eu for the EU site,
+// authenticating with APIKey. Every data point is posted as a gauge named after the namespace and the name of
+// the point in snake case, e.g. cw_monitor.cpu_utilization, with the Host dimension as host and the other
+// dimensions as tags of the form dimension:value. Points are posted in batches of at most BatchSize series.
+type DatadogSink struct {
+	httpRetry
+
+	URL       string
+	APIKey    string
+	Namespace string
+	BatchSize int
+}
+
+// Name of the Datadog sink
+func (s DatadogSink) Name() string {
+	return "datadog"
+}
+
+func (s DatadogSink) url() string {
+	if s.URL != "" {
+		return strings.TrimSuffix(s.URL, "/") + datadogSeriesPath
+	}
+	return defaultDatadogURL + datadogSeriesPath
+}
+
+func (s DatadogSink) batchSize() int {
+	if s.BatchSize > 0 {
+		return s.BatchSize
+	}
+	return defaultDatadogBatchSize
+}
+
+// series converts the data points into Datadog series skipping NaN and infinite values
+func (s DatadogSink) series(data metrics.Data) []datadogSeries {
+	prefix := toSnakeCase(s.Namespace)
+	series := make([]datadogSeries, 0, len(data))
+	for _, p := range data {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+
+		name := toSnakeCase(p.Name)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		ds := datadogSeries{
+			Metric: name,
+			Points: [][2]float64{{float64(p.Timestamp.Unix()), p.Value}},
+			Type:   "gauge",
+		}
+		for _, d := range p.Dimensions {
+			if d.Name == "Host" {
+				ds.Host = d.Value
+				continue
+			}
+			ds.Tags = append(ds.Tags, toSnakeCase(d.Name)+":"+d.Value)
+		}
+		series = append(series, ds)
+	}
+	return series
+}
+
+// Publish posts the data to Datadog in batches, retrying the failed requests
+func (s DatadogSink) Publish(data metrics.Data) error {
+	log.Debug("posting data points to datadog")
+	multierror := util.MultiError{}
+	for _, batch := range data.Batch(s.batchSize()) {
+		series := s.series(batch)
+		if len(series) == 0 {
+			continue
+		}
+
+		body, err := json.Marshal(datadogRequest{Series: series})
+		if err != nil {
+			multierror.Add(errors.Wrap(err, "failed to encode datadog series"))
+			continue
+		}
+
+		multierror.Add(s.send("datadog", func() (*http.Request, error) {
+			request, err := http.NewRequest(http.MethodPost, s.url(), bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set("DD-API-KEY", s.APIKey)
+			return request, nil
+		}))
+	}
+	return multierror.ErrorOrNil()
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+var datadogTimestamp = time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+
+func TestDatadogSink_url(t *testing.T) {
+	assert.Equal(t, "https://api.datadoghq.com/api/v1/series", DatadogSink{}.url())
+	assert.Equal(t, "https://api.datadoghq.eu/api/v1/series", DatadogSink{URL: "https://api.datadoghq.eu/"}.url())
+}
+
+func TestDatadogSink_series(t *testing.T) {
+	s := DatadogSink{Namespace: "CWMonitor"}
+	data := metrics.Data{
+		{Name: "CPUUtilization", Value: 12.5, Timestamp: datadogTimestamp, Dimensions: []metrics.Dimension{
+			{Name: "Host", Value: "a"}, {Name: "ContainerName", Value: "web"},
+		}},
+		{Name: "Up", Value: 1, Timestamp: datadogTimestamp},
+		{Name: "Invalid", Value: math.NaN(), Timestamp: datadogTimestamp},
+	}
+
+	assert.Equal(t, []datadogSeries{
+		{
+			Metric: "cw_monitor.cpu_utilization",
+			Points: [][2]float64{{1535796000, 12.5}},
+			Type:   "gauge",
+			Host:   "a",
+			Tags:   []string{"container_name:web"},
+		},
+		{Metric: "cw_monitor.up", Points: [][2]float64{{1535796000, 1}}, Type: "gauge"},
+	}, s.series(data))
+}
+
+func TestDatadogSink_Publish(t *testing.T) {
+	bodies := []string{}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/api/v1/series", r.URL.Path)
+		assert.Equal(t, "key", r.Header.Get("DD-API-KEY"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s := DatadogSink{
+		httpRetry: httpRetry{Retries: 1, Backoff: time.Millisecond},
+		URL:       server.URL,
+		APIKey:    "key",
+		Namespace: "ns",
+		BatchSize: 1,
+	}
+	data := metrics.Data{
+		{Name: "Up", Value: 1, Timestamp: datadogTimestamp},
+		{Name: "Down", Value: 0, Timestamp: datadogTimestamp},
+	}
+
+	assert.NoError(t, s.Publish(data))
+	assert.Equal(t, 3, requests)
+	assert.Len(t, bodies, 2)
+	assert.JSONEq(t, `{"series":[{"metric":"ns.up","points":[[1535796000,1]],"type":"gauge"}]}`, bodies[0])
+	assert.JSONEq(t, `{"series":[{"metric":"ns.down","points":[[1535796000,0]],"type":"gauge"}]}`, bodies[1])
+}
+
+func TestDatadogSink_Publish_error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	s := DatadogSink{URL: server.URL, APIKey: "invalid"}
+	err := s.Publish(metrics.Data{{Name: "Up", Value: 1, Timestamp: datadogTimestamp}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "403 Forbidden")
+}
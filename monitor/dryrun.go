@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/pkg/errors"
+)
+
+// DryRunClient is a CloudWatch client printing the PutMetricData requests, as a table or as JSON,
+// instead of sending them to CloudWatch. It does not require AWS credentials.
+type DryRunClient struct {
+	cloudwatchiface.CloudWatchAPI
+
+	Format string
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewDryRunClient creates a client printing the requests to out in the given format, table or json
+func NewDryRunClient(out io.Writer, format string) *DryRunClient {
+	return &DryRunClient{Format: format, out: out}
+}
+
+// PutMetricData prints the request
+func (c *DryRunClient) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	if c.Format == "json" {
+		err = json.NewEncoder(c.out).Encode(input)
+	} else {
+		err = c.printTable(input)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to print metric data")
+	}
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+// printTable prints the request as a table with a row for every datum
+func (c *DryRunClient) printTable(input *cloudwatch.PutMetricDataInput) error {
+	fmt.Fprintf(c.out, "PutMetricData namespace=%s datums=%d\n", aws.StringValue(input.Namespace), len(input.MetricData))
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE\tUNIT\tTIMESTAMP\tDIMENSIONS")
+	for _, datum := range input.MetricData {
+		dimensions := make([]string, 0, len(datum.Dimensions))
+		for _, d := range datum.Dimensions {
+			dimensions = append(dimensions, aws.StringValue(d.Name)+"="+aws.StringValue(d.Value))
+		}
+		sort.Strings(dimensions)
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			aws.StringValue(datum.MetricName),
+			strconv.FormatFloat(aws.Float64Value(datum.Value), 'f', -1, 64),
+			aws.StringValue(datum.Unit),
+			aws.TimeValue(datum.Timestamp).Format(time.RFC3339),
+			strings.Join(dimensions, ","))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(c.out)
+	return err
+}
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/stretchr/testify/assert"
+)
+
+func dryRunInput() *cloudwatch.PutMetricDataInput {
+	timestamp := time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+	return &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String("CWMonitor"),
+		MetricData: []*cloudwatch.MetricDatum{
+			{
+				MetricName: aws.String("CPUUtilization"),
+				Unit:       aws.String("Percent"),
+				Value:      aws.Float64(12.5),
+				Timestamp:  aws.Time(timestamp),
+				Dimensions: []*cloudwatch.Dimension{
+					{Name: aws.String("Host"), Value: aws.String("a")},
+					{Name: aws.String("Container"), Value: aws.String("web")},
+				},
+			},
+			{
+				MetricName: aws.String("MemoryUtilization"),
+				Unit:       aws.String("Percent"),
+				Value:      aws.Float64(50),
+				Timestamp:  aws.Time(timestamp),
+				Dimensions: []*cloudwatch.Dimension{},
+			},
+		},
+	}
+}
+
+func TestDryRunClient_PutMetricData(t *testing.T) {
+	t.Run("table", func(t *testing.T) {
+		var b bytes.Buffer
+		c := NewDryRunClient(&b, "table")
+
+		output, err := c.PutMetricData(dryRunInput())
+
+		assert.NoError(t, err)
+		assert.Equal(t, &cloudwatch.PutMetricDataOutput{}, output)
+		assert.Equal(t, `PutMetricData namespace=CWMonitor datums=2
+NAME               VALUE  UNIT     TIMESTAMP             DIMENSIONS
+CPUUtilization     12.5   Percent  2018-09-01T10:00:00Z  Container=web,Host=a
+MemoryUtilization  50     Percent  2018-09-01T10:00:00Z  
+
+`, b.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var b bytes.Buffer
+		c := NewDryRunClient(&b, "json")
+
+		_, err := c.PutMetricData(dryRunInput())
+		assert.NoError(t, err)
+		_, err = c.PutMetricData(dryRunInput())
+		assert.NoError(t, err)
+
+		decoder := json.NewDecoder(&b)
+		for i := 0; i < 2; i++ {
+			var input cloudwatch.PutMetricDataInput
+			assert.NoError(t, decoder.Decode(&input))
+			assert.Equal(t, dryRunInput(), &input)
+		}
+		assert.False(t, decoder.More())
+	})
+}
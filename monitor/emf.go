@@ -0,0 +1,285 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxEMFMetrics is the maximum number of metrics in a document accepted by CloudWatch
+	maxEMFMetrics     = 100
+	defaultEMFOutput  = "stdout"
+	defaultEMFTimeout = 5 * time.Second
+)
+
+// emfMetric is the definition of a metric in an EMF document
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+// emfDirective instructs CloudWatch which members of an EMF document are metrics and dimensions
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+// emfMetadata is the _aws member of an EMF document
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// emfDocument is an EMF document under construction
+type emfDocument struct {
+	directive emfDirective
+	timestamp int64
+	members   map[string]interface{}
+}
+
+// encode the document as a single line of JSON
+func (d *emfDocument) encode() ([]byte, error) {
+	members := map[string]interface{}{}
+	for k, v := range d.members {
+		members[k] = v
+	}
+	members["_aws"] = emfMetadata{Timestamp: d.timestamp, CloudWatchMetrics: []emfDirective{d.directive}}
+	return json.Marshal(members)
+}
+
+// gatherTimestamp returns the timestamp in milliseconds of the gathering of the data, the time of its first point
+func gatherTimestamp(data metrics.Data) int64 {
+	var timestamp time.Time
+	for _, p := range data {
+		if timestamp.IsZero() || p.Timestamp.Before(timestamp) {
+			timestamp = p.Timestamp
+		}
+	}
+	return timestamp.UnixNano() / int64(time.Millisecond)
+}
+
+// emfDocuments groups the data points with the same dimensions into CloudWatch Embedded Metric Format
+// documents of at most maxEMFMetrics metrics, in order of appearance. The points of a Gather are taken
+// a few milliseconds apart so every document carries the timestamp of the Gather. A new document is
+// started when a point would overwrite a member of the document, e.g. a metric reported twice.
+// NaN and infinite values and points named after one of their dimensions are skipped.
+func emfDocuments(namespace string, data metrics.Data) []*emfDocument {
+	timestamp := gatherTimestamp(data)
+	documents := []*emfDocument{}
+	open := map[string]*emfDocument{}
+	for _, p := range data {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+
+		dimensions := append([]metrics.Dimension{}, p.Dimensions...)
+		sort.SliceStable(dimensions, func(i, j int) bool { return dimensions[i].Name < dimensions[j].Name })
+		names := make([]string, 0, len(dimensions))
+		parts := make([]string, 0, len(dimensions))
+		for _, d := range dimensions {
+			names = append(names, d.Name)
+			parts = append(parts, d.Name+"="+d.Value)
+		}
+		key := strings.Join(parts, "|")
+
+		if contains(names, p.Name) {
+			log.Debugf("skipping emf data point [%s] named after one of its dimensions", p.Name)
+			continue
+		}
+
+		document, ok := open[key]
+		if ok {
+			_, duplicate := document.members[p.Name]
+			ok = !duplicate && len(document.directive.Metrics) < maxEMFMetrics
+		}
+		if !ok {
+			document = &emfDocument{
+				directive: emfDirective{Namespace: namespace, Dimensions: [][]string{names}, Metrics: []emfMetric{}},
+				timestamp: timestamp,
+				members:   map[string]interface{}{},
+			}
+			for _, d := range dimensions {
+				document.members[d.Name] = d.Value
+			}
+			open[key] = document
+			documents = append(documents, document)
+		}
+
+		document.directive.Metrics = append(document.directive.Metrics, emfMetric{Name: p.Name, Unit: string(p.Unit)})
+		document.members[p.Name] = p.Value
+	}
+	return documents
+}
+
+// contains returns true if the value is one of the values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// EMFSink writes the data as CloudWatch Embedded Metric Format documents, one per line, to Output:
+// stdout, a file given by its path, or the TCP or UDP endpoint of a CloudWatch agent given as
+// tcp://host:port or udp://host:port. CloudWatch extracts the metrics from the documents ingested
+// by CloudWatch Logs, e.g. through the awslogs driver, without calls to PutMetricData.
+type EMFSink struct {
+	Namespace string
+	Output    string
+	Timeout   time.Duration
+
+	mu     sync.Mutex
+	stdout io.Writer
+	file   *os.File
+	conn   net.Conn
+}
+
+// NewEMFSink creates a sink writing EMF documents for the namespace to the output
+func NewEMFSink(namespace, output string) *EMFSink {
+	return &EMFSink{Namespace: namespace, Output: output, stdout: os.Stdout}
+}
+
+// Name of the EMF sink
+func (s *EMFSink) Name() string {
+	return "emf"
+}
+
+func (s *EMFSink) output() string {
+	if s.Output != "" {
+		return s.Output
+	}
+	return defaultEMFOutput
+}
+
+func (s *EMFSink) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultEMFTimeout
+}
+
+// network returns the network and address of the output or false if the output is not a network endpoint
+func (s *EMFSink) network() (string, string, bool) {
+	for _, network := range []string{"tcp", "udp"} {
+		if address := strings.TrimPrefix(s.output(), network+"://"); address != s.output() {
+			return network, address, true
+		}
+	}
+	return "", "", false
+}
+
+// writer returns the writer for the output, opening the file or the connection if not already open
+func (s *EMFSink) writer() (io.Writer, error) {
+	output := s.output()
+	if output == "stdout" {
+		return s.stdout, nil
+	}
+
+	if network, address, ok := s.network(); ok {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(network, address, s.timeout())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to connect to emf endpoint [%s]", output)
+			}
+			s.conn = conn
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+		return s.conn, nil
+	}
+
+	if s.file == nil {
+		file, err := os.OpenFile(strings.TrimPrefix(output, "file://"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open emf file [%s]", output)
+		}
+		s.file = file
+	}
+	return s.file, nil
+}
+
+// write the payload to the output, closing the connection on failure so that it is reopened by the next write
+func (s *EMFSink) write(payload []byte) error {
+	w, err := s.writer()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		return errors.Wrapf(err, "failed to write emf documents to [%s]", s.output())
+	}
+	return nil
+}
+
+// Publish writes the data as EMF documents grouping the points with the same dimensions.
+// Every document is written separately to UDP endpoints, as a datagram, and the write to TCP endpoints
+// is retried once on a new connection if it fails.
+func (s *EMFSink) Publish(data metrics.Data) error {
+	log.Debug("writing data points as emf documents")
+	payloads := [][]byte{}
+	for _, document := range emfDocuments(s.Namespace, data) {
+		line, err := document.encode()
+		if err != nil {
+			return errors.Wrap(err, "failed to encode emf document")
+		}
+		payloads = append(payloads, append(line, '\n'))
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	network, _, _ := s.network()
+	if network != "udp" {
+		payloads = [][]byte{bytes.Join(payloads, nil)}
+	}
+	for _, payload := range payloads {
+		if err := s.write(payload); err != nil {
+			if network != "tcp" {
+				return err
+			}
+			log.Debugf("retrying emf write: %s", err)
+			if err := s.write(payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the output file or connection
+func (s *EMFSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.file != nil {
+		err = s.file.Close()
+		s.file = nil
+	}
+	if s.conn != nil {
+		err = s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
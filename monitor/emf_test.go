@@ -0,0 +1,243 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+var emfTimestamp = time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+
+func TestEMFDocuments(t *testing.T) {
+	t.Run("groups by dimensions", func(t *testing.T) {
+		data := metrics.Data{
+			{Name: "CPUUtilization", Value: 10, Unit: metrics.UnitPercent, Timestamp: emfTimestamp, Dimensions: []metrics.Dimension{
+				{Name: "Host", Value: "a"}, {Name: "Container", Value: "web"},
+			}},
+			{Name: "DiskUtilization", Value: 30, Unit: metrics.UnitPercent, Timestamp: emfTimestamp, Dimensions: []metrics.Dimension{
+				{Name: "Host", Value: "a"},
+			}},
+			{Name: "MemoryUtilization", Value: 20, Unit: metrics.UnitPercent, Timestamp: emfTimestamp.Add(3 * time.Millisecond), Dimensions: []metrics.Dimension{
+				{Name: "Container", Value: "web"}, {Name: "Host", Value: "a"},
+			}},
+			{Name: "Invalid", Value: math.NaN(), Timestamp: emfTimestamp},
+			{Name: "Host", Value: 1, Timestamp: emfTimestamp, Dimensions: []metrics.Dimension{{Name: "Host", Value: "a"}}},
+		}
+
+		documents := emfDocuments("ns", data)
+		assert.Len(t, documents, 2)
+
+		line, err := documents[0].encode()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"_aws": {
+				"Timestamp": 1535796000000,
+				"CloudWatchMetrics": [{
+					"Namespace": "ns",
+					"Dimensions": [["Container", "Host"]],
+					"Metrics": [{"Name": "CPUUtilization", "Unit": "Percent"}, {"Name": "MemoryUtilization", "Unit": "Percent"}]
+				}]
+			},
+			"Container": "web",
+			"Host": "a",
+			"CPUUtilization": 10,
+			"MemoryUtilization": 20
+		}`, string(line))
+
+		line, err = documents[1].encode()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"_aws": {
+				"Timestamp": 1535796000000,
+				"CloudWatchMetrics": [{"Namespace": "ns", "Dimensions": [["Host"]], "Metrics": [{"Name": "DiskUtilization", "Unit": "Percent"}]}]
+			},
+			"Host": "a",
+			"DiskUtilization": 30
+		}`, string(line))
+	})
+
+	t.Run("without dimensions", func(t *testing.T) {
+		documents := emfDocuments("ns", metrics.Data{{Name: "Up", Value: 1, Timestamp: emfTimestamp}})
+
+		assert.Len(t, documents, 1)
+		line, err := documents[0].encode()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"_aws": {"Timestamp": 1535796000000, "CloudWatchMetrics": [{"Namespace": "ns", "Dimensions": [[]], "Metrics": [{"Name": "Up"}]}]},
+			"Up": 1
+		}`, string(line))
+	})
+
+	t.Run("splits duplicates", func(t *testing.T) {
+		documents := emfDocuments("ns", metrics.Data{
+			{Name: "Up", Value: 1, Timestamp: emfTimestamp.Add(time.Millisecond)},
+			{Name: "Up", Value: 0, Timestamp: emfTimestamp},
+			{Name: "Down", Value: 1, Timestamp: emfTimestamp.Add(time.Second)},
+		})
+
+		assert.Len(t, documents, 2)
+		assert.Len(t, documents[1].directive.Metrics, 2)
+		for _, document := range documents {
+			assert.Equal(t, int64(1535796000000), document.timestamp)
+		}
+	})
+
+	t.Run("at most 100 metrics per document", func(t *testing.T) {
+		data := metrics.Data{}
+		for i := 0; i < 250; i++ {
+			data = append(data, &metrics.Point{Name: "Metric" + strconv.Itoa(i), Value: float64(i), Timestamp: emfTimestamp})
+		}
+
+		documents := emfDocuments("ns", data)
+		assert.Len(t, documents, 3)
+		assert.Len(t, documents[0].directive.Metrics, 100)
+		assert.Len(t, documents[1].directive.Metrics, 100)
+		assert.Len(t, documents[2].directive.Metrics, 50)
+		assert.Equal(t, 200.0, documents[2].members["Metric200"])
+	})
+}
+
+func emfData() metrics.Data {
+	return metrics.Data{
+		{Name: "CPUUtilization", Value: 10, Timestamp: emfTimestamp, Dimensions: []metrics.Dimension{{Name: "Host", Value: "a"}}},
+		{Name: "CPUUtilization", Value: 20, Timestamp: emfTimestamp, Dimensions: []metrics.Dimension{{Name: "Host", Value: "b"}}},
+	}
+}
+
+// decodeEMF returns the values of the CPUUtilization member of the EMF documents, one per line
+func decodeEMF(t *testing.T, output string) []float64 {
+	values := []float64{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var document map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &document))
+		assert.Contains(t, document, "_aws")
+		values = append(values, document["CPUUtilization"].(float64))
+	}
+	return values
+}
+
+func TestEMFSink_Publish(t *testing.T) {
+	t.Run("stdout", func(t *testing.T) {
+		var b bytes.Buffer
+		s := NewEMFSink("ns", "")
+		s.stdout = &b
+
+		assert.Equal(t, "emf", s.Name())
+		assert.NoError(t, s.Publish(emfData()))
+		assert.Equal(t, []float64{10, 20}, decodeEMF(t, b.String()))
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "emf")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "emf.log")
+		s := NewEMFSink("ns", "file://"+path)
+		assert.NoError(t, s.Publish(emfData()))
+		assert.NoError(t, s.Publish(emfData()[:1]))
+		assert.NoError(t, s.Close())
+
+		output, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, []float64{10, 20, 10}, decodeEMF(t, string(output)))
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		s := NewEMFSink("ns", "/nonexistent/emf.log")
+		err := s.Publish(emfData())
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to open emf file")
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		lines := make(chan string, 10)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					scanner := bufio.NewScanner(conn)
+					for scanner.Scan() {
+						lines <- scanner.Text()
+					}
+				}()
+			}
+		}()
+
+		s := NewEMFSink("ns", "tcp://"+listener.Addr().String())
+		defer s.Close()
+		assert.NoError(t, s.Publish(emfData()))
+
+		// a broken connection is reopened on the next write
+		s.conn.Close()
+		assert.NoError(t, s.Publish(emfData()[1:]))
+
+		values := []float64{}
+		for i := 0; i < 3; i++ {
+			select {
+			case line := <-lines:
+				values = append(values, decodeEMF(t, line)...)
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for emf document")
+			}
+		}
+		// the documents written on different connections are not received in order
+		sort.Float64s(values)
+		assert.Equal(t, []float64{10, 20, 20}, values)
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		s := NewEMFSink("ns", "udp://"+conn.LocalAddr().String())
+		defer s.Close()
+		assert.NoError(t, s.Publish(emfData()))
+
+		buf := make([]byte, 65535)
+		values := []float64{}
+		for i := 0; i < 2; i++ {
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			assert.NoError(t, err)
+			values = append(values, decodeEMF(t, string(buf[:n]))...)
+		}
+		assert.Equal(t, []float64{10, 20}, values)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		address := listener.Addr().String()
+		listener.Close()
+
+		s := NewEMFSink("ns", "tcp://"+address)
+		err = s.Publish(emfData())
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to connect to emf endpoint")
+	})
+}
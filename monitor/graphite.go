@@ -0,0 +1,241 @@
+package monitor
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultGraphiteProtocol = "tcp"
+	defaultGraphiteTemplate = "{namespace}.{dimensions}.{name}"
+	defaultGraphiteTimeout  = 5 * time.Second
+	// maxGraphitePacketSize keeps the UDP packets within the MTU of most networks
+	maxGraphitePacketSize = 1400
+)
+
+var graphitePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// sanitiseGraphite replaces the characters not allowed in a node of a Graphite path with underscores.
+// Dots are kept if keepDots is set, e.g. for names already in the dotted Graphite form.
+func sanitiseGraphite(s string, keepDots bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == '.' && keepDots:
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// GraphiteSink writes the data to Graphite, e.g. carbon, at Address with the plaintext protocol over TCP
+// or UDP. The path of a data point is built from Template where {namespace} is replaced by Namespace,
+// {name} by the name of the point, {dimensions} by the values of the dimensions not used elsewhere in
+// the template, sorted by name, and any other {Dimension} by the value of the dimension with that name.
+// Placeholders of missing dimensions are dropped from the path.
+type GraphiteSink struct {
+	Address   string
+	Protocol  string
+	Template  string
+	Namespace string
+	Timeout   time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewGraphiteSink creates a sink writing to the Graphite at the address with the given protocol
+// and path template
+func NewGraphiteSink(address, protocol, template, namespace string) *GraphiteSink {
+	return &GraphiteSink{Address: address, Protocol: protocol, Template: template, Namespace: namespace}
+}
+
+// Name of the Graphite sink
+func (s *GraphiteSink) Name() string {
+	return "graphite"
+}
+
+func (s *GraphiteSink) protocol() string {
+	if s.Protocol != "" {
+		return s.Protocol
+	}
+	return defaultGraphiteProtocol
+}
+
+func (s *GraphiteSink) template() string {
+	if s.Template != "" {
+		return s.Template
+	}
+	return defaultGraphiteTemplate
+}
+
+func (s *GraphiteSink) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultGraphiteTimeout
+}
+
+// path returns the Graphite path of the data point
+func (s *GraphiteSink) path(point *metrics.Point) string {
+	template := s.template()
+	dimensions := map[string]string{}
+	for _, d := range point.Dimensions {
+		dimensions[d.Name] = d.Value
+	}
+
+	used := map[string]bool{}
+	for _, placeholder := range graphitePlaceholder.FindAllString(template, -1) {
+		used[placeholder[1:len(placeholder)-1]] = true
+	}
+
+	nodes := []string{}
+	for _, node := range strings.Split(template, ".") {
+		node = graphitePlaceholder.ReplaceAllStringFunc(node, func(placeholder string) string {
+			switch key := placeholder[1 : len(placeholder)-1]; key {
+			case "namespace":
+				return sanitiseGraphite(s.Namespace, true)
+			case "name":
+				return sanitiseGraphite(point.Name, true)
+			case "dimensions":
+				names := []string{}
+				for name := range dimensions {
+					if !used[name] && dimensions[name] != "" {
+						names = append(names, name)
+					}
+				}
+				sort.Strings(names)
+				values := make([]string, 0, len(names))
+				for _, name := range names {
+					values = append(values, sanitiseGraphite(dimensions[name], false))
+				}
+				return strings.Join(values, ".")
+			default:
+				return sanitiseGraphite(dimensions[key], false)
+			}
+		})
+		if node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	return strings.Join(nodes, ".")
+}
+
+// lines returns the data in the plaintext protocol, one line of the form path value timestamp
+// for every point, skipping NaN and infinite values
+func (s *GraphiteSink) lines(data metrics.Data) []string {
+	lines := make([]string, 0, len(data))
+	for _, p := range data {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		timestamp := p.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		value := strconv.FormatFloat(p.Value, 'f', -1, 64)
+		lines = append(lines, s.path(p)+" "+value+" "+strconv.FormatInt(timestamp.Unix(), 10)+"\n")
+	}
+	return lines
+}
+
+// packets groups the lines in the payloads written to the connection. TCP streams all lines in a
+// single payload while UDP splits them in packets of at most maxGraphitePacketSize bytes.
+func (s *GraphiteSink) packets(lines []string) [][]byte {
+	if s.protocol() != "udp" {
+		return [][]byte{[]byte(strings.Join(lines, ""))}
+	}
+
+	packets := [][]byte{}
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line) > maxGraphitePacketSize {
+			packets = append(packets, append([]byte{}, packet.Bytes()...))
+			packet.Reset()
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		packets = append(packets, packet.Bytes())
+	}
+	return packets
+}
+
+// connect opens a connection to Graphite if not already open
+func (s *GraphiteSink) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout(s.protocol(), s.Address, s.timeout())
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to graphite [%s]", s.Address)
+	}
+	s.conn = conn
+	return nil
+}
+
+// write the payload to the connection, closing the connection on failure
+func (s *GraphiteSink) write(payload []byte) error {
+	if err := s.connect(); err != nil {
+		return err
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+	if _, err := s.conn.Write(payload); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return errors.Wrapf(err, "failed to write data to graphite [%s]", s.Address)
+	}
+	return nil
+}
+
+// Publish writes the data to Graphite. The connection is kept open between calls and reopened if
+// writing fails, in which case the write is retried once.
+func (s *GraphiteSink) Publish(data metrics.Data) error {
+	log.Debug("writing data points to graphite")
+	lines := s.lines(data)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, payload := range s.packets(lines) {
+		if err := s.write(payload); err != nil {
+			log.Debugf("retrying graphite write: %s", err)
+			if err := s.write(payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to Graphite
+func (s *GraphiteSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
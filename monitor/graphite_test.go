@@ -0,0 +1,174 @@
+package monitor
+
+import (
+	"bufio"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitiseGraphite(t *testing.T) {
+	assert.Equal(t, "web-1_a_b", sanitiseGraphite("web-1/a b", false))
+	assert.Equal(t, "_var_lib", sanitiseGraphite("/var.lib", false))
+	assert.Equal(t, "latency.p90", sanitiseGraphite("latency.p90", true))
+}
+
+func TestGraphiteSink_path(t *testing.T) {
+	point := metrics.Point{Name: "CPUUtilization", Dimensions: []metrics.Dimension{
+		{Name: "Host", Value: "i-123"}, {Name: "Container", Value: "web.1"}, {Name: "Image", Value: "nginx:latest"},
+	}}
+
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{template: "", expected: "CWMonitor.web_1.i-123.nginx_latest.CPUUtilization"},
+		{template: "{namespace}.{Host}.{Container}.{name}", expected: "CWMonitor.i-123.web_1.CPUUtilization"},
+		{template: "{namespace}.{Host}.{dimensions}.{name}", expected: "CWMonitor.i-123.web_1.nginx_latest.CPUUtilization"},
+		{template: "servers.{Host}.{Missing}.{name}", expected: "servers.i-123.CPUUtilization"},
+		{template: "{Host}_{Container}.{name}", expected: "i-123_web_1.CPUUtilization"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			s := NewGraphiteSink("", "", tc.template, "CWMonitor")
+			assert.Equal(t, tc.expected, s.path(&point))
+		})
+	}
+}
+
+func TestGraphiteSink_lines(t *testing.T) {
+	s := NewGraphiteSink("", "", "{namespace}.{name}", "ns")
+	timestamp := time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+	lines := s.lines(metrics.Data{
+		{Name: "latency.p90", Value: 12.5, Timestamp: timestamp},
+		{Name: "Invalid", Value: math.NaN(), Timestamp: timestamp},
+		{Name: "Large", Value: 1e21, Timestamp: timestamp},
+	})
+
+	assert.Equal(t, []string{
+		"ns.latency.p90 12.5 1535796000\n",
+		"ns.Large 1000000000000000000000 1535796000\n",
+	}, lines)
+}
+
+func TestGraphiteSink_packets(t *testing.T) {
+	line := strings.Repeat("a", 500) + " 1 1535796000\n"
+	lines := []string{line, line, line, line}
+
+	assert.Len(t, NewGraphiteSink("", "tcp", "", "").packets(lines), 1)
+	packets := NewGraphiteSink("", "udp", "", "").packets(lines)
+	assert.Len(t, packets, 2)
+	assert.Equal(t, line+line, string(packets[0]))
+	assert.Equal(t, line+line, string(packets[1]))
+}
+
+// graphiteServer accepts TCP connections and sends the received lines on a channel
+func graphiteServer(t *testing.T) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return listener, lines
+}
+
+func receive(t *testing.T, lines chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for graphite line")
+		return ""
+	}
+}
+
+func TestGraphiteSink_Publish(t *testing.T) {
+	timestamp := time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+	data := metrics.Data{
+		{Name: "CPUUtilization", Value: 10, Timestamp: timestamp, Dimensions: []metrics.Dimension{{Name: "Host", Value: "a"}}},
+		{Name: "MemoryUtilization", Value: 20, Timestamp: timestamp, Dimensions: []metrics.Dimension{{Name: "Host", Value: "a"}}},
+	}
+
+	t.Run("tcp", func(t *testing.T) {
+		listener, lines := graphiteServer(t)
+		defer listener.Close()
+
+		s := NewGraphiteSink(listener.Addr().String(), "tcp", "", "ns")
+		defer s.Close()
+
+		assert.Equal(t, "graphite", s.Name())
+		assert.NoError(t, s.Publish(data))
+		assert.Equal(t, "ns.a.CPUUtilization 10 1535796000", receive(t, lines))
+		assert.Equal(t, "ns.a.MemoryUtilization 20 1535796000", receive(t, lines))
+	})
+
+	t.Run("reconnects after failure", func(t *testing.T) {
+		listener, lines := graphiteServer(t)
+		defer listener.Close()
+
+		s := NewGraphiteSink(listener.Addr().String(), "tcp", "", "ns")
+		defer s.Close()
+
+		assert.NoError(t, s.Publish(data[:1]))
+		assert.Equal(t, "ns.a.CPUUtilization 10 1535796000", receive(t, lines))
+
+		// a broken connection is reopened on the next write
+		s.conn.Close()
+		assert.NoError(t, s.Publish(data[1:]))
+		assert.Equal(t, "ns.a.MemoryUtilization 20 1535796000", receive(t, lines))
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		s := NewGraphiteSink(conn.LocalAddr().String(), "udp", "{name}", "ns")
+		defer s.Close()
+		assert.NoError(t, s.Publish(data))
+
+		buf := make([]byte, maxGraphitePacketSize)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "CPUUtilization 10 1535796000\nMemoryUtilization 20 1535796000\n", string(buf[:n]))
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		listener, _ := graphiteServer(t)
+		address := listener.Addr().String()
+		listener.Close()
+
+		s := NewGraphiteSink(address, "tcp", "", "ns")
+		err := s.Publish(data)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to connect to graphite")
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		s := NewGraphiteSink("127.0.0.1:1", "tcp", "", "ns")
+		assert.NoError(t, s.Publish(metrics.Data{}))
+		assert.NoError(t, s.Close())
+	})
+}
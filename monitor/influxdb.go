@@ -0,0 +1,191 @@
+package monitor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/dedalusj/cwmonitor/util"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInfluxDBBatchSize = 5000
+	defaultInfluxDBTimeout   = 10 * time.Second
+)
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+// writeInfluxLine writes the data point in the InfluxDB line protocol, with the name of the point as
+// measurement, its dimensions as tags sorted by name, the value as the value field and the timestamp
+// in nanoseconds. Dimensions with an empty value are dropped as InfluxDB does not accept empty tags.
+// It returns false for points whose value cannot be written, i.e. NaN or infinite values.
+func writeInfluxLine(w io.Writer, point *metrics.Point) (bool, error) {
+	if math.IsNaN(point.Value) || math.IsInf(point.Value, 0) {
+		return false, nil
+	}
+
+	tags := make([]string, 0, len(point.Dimensions))
+	for _, d := range point.Dimensions {
+		if d.Value != "" {
+			tags = append(tags, influxTagEscaper.Replace(d.Name)+"="+influxTagEscaper.Replace(d.Value))
+		}
+	}
+	sort.Strings(tags)
+
+	line := influxMeasurementEscaper.Replace(point.Name)
+	if len(tags) > 0 {
+		line += "," + strings.Join(tags, ",")
+	}
+	line += " value=" + strconv.FormatFloat(point.Value, 'g', -1, 64)
+	if !point.Timestamp.IsZero() {
+		line += " " + strconv.FormatInt(point.Timestamp.UnixNano(), 10)
+	}
+
+	_, err := io.WriteString(w, line+"\n")
+	return err == nil, err
+}
+
+// InfluxDBSink writes the data in the line protocol to the v1 /write endpoint of the InfluxDB at URL,
+// into Database, or to the v2 /api/v2/write endpoint, into Bucket of Org, if Bucket is set.
+// The v1 endpoint authenticates with Username and Password while the v2 endpoint with Token.
+// Data is written in batches of at most BatchSize points, compressed with gzip if Gzip is set.
+type InfluxDBSink struct {
+	URL       string
+	Database  string
+	Username  string
+	Password  string
+	Org       string
+	Bucket    string
+	Token     string
+	BatchSize int
+	Gzip      bool
+	Timeout   time.Duration
+}
+
+// Name of the InfluxDB sink
+func (s InfluxDBSink) Name() string {
+	return "influxdb"
+}
+
+func (s InfluxDBSink) batchSize() int {
+	if s.BatchSize > 0 {
+		return s.BatchSize
+	}
+	return defaultInfluxDBBatchSize
+}
+
+func (s InfluxDBSink) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultInfluxDBTimeout
+}
+
+// writeURL returns the URL of the write endpoint
+func (s InfluxDBSink) writeURL() string {
+	query := url.Values{}
+	query.Set("precision", "ns")
+	endpoint := "/write"
+	if s.Bucket != "" {
+		endpoint = "/api/v2/write"
+		query.Set("org", s.Org)
+		query.Set("bucket", s.Bucket)
+	} else {
+		query.Set("db", s.Database)
+	}
+	return strings.TrimSuffix(s.URL, "/") + endpoint + "?" + query.Encode()
+}
+
+// encode returns the body of the write request for the batch
+func (s InfluxDBSink) encode(batch metrics.Data) (*bytes.Buffer, int, error) {
+	var body bytes.Buffer
+	var w io.Writer = &body
+	var gz *gzip.Writer
+	if s.Gzip {
+		gz = gzip.NewWriter(&body)
+		w = gz
+	}
+
+	written := 0
+	for _, p := range batch {
+		ok, err := writeInfluxLine(w, p)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			written++
+		}
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return &body, written, nil
+}
+
+// write sends a batch to the write endpoint
+func (s InfluxDBSink) write(client *http.Client, batch metrics.Data) error {
+	body, written, err := s.encode(batch)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode data in line protocol")
+	}
+	if written == 0 {
+		return nil
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.writeURL(), body)
+	if err != nil {
+		return errors.Wrap(err, "failed to create influxdb write request")
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.Gzip {
+		request.Header.Set("Content-Encoding", "gzip")
+	}
+	switch {
+	case s.Token != "":
+		request.Header.Set("Authorization", "Token "+s.Token)
+	case s.Username != "":
+		request.SetBasicAuth(s.Username, s.Password)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to write data to influxdb")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return errors.Errorf("failed to write data to influxdb: unexpected status [%s]: %s",
+			response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Publish writes the data to InfluxDB in batches. NaN and infinite values are skipped.
+func (s InfluxDBSink) Publish(data metrics.Data) error {
+	log.Debug("writing data points to influxdb")
+	client := &http.Client{Timeout: s.timeout()}
+	multierror := util.MultiError{}
+	for _, batch := range data.Batch(s.batchSize()) {
+		multierror.Add(s.write(client, batch))
+	}
+	return multierror.ErrorOrNil()
+}
@@ -0,0 +1,218 @@
+package monitor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteInfluxLine(t *testing.T) {
+	timestamp := time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		point    metrics.Point
+		expected string
+		written  bool
+	}{
+		{
+			name:     "without dimensions",
+			point:    metrics.Point{Name: "CPUUtilization", Value: 12.5, Timestamp: timestamp},
+			expected: "CPUUtilization value=12.5 1535796000000000000\n",
+			written:  true,
+		},
+		{
+			name: "dimensions sorted by name",
+			point: metrics.Point{Name: "MemoryUtilization", Value: 50, Timestamp: timestamp, Dimensions: []metrics.Dimension{
+				{Name: "Host", Value: "a"}, {Name: "Container", Value: "web"},
+			}},
+			expected: "MemoryUtilization,Container=web,Host=a value=50 1535796000000000000\n",
+			written:  true,
+		},
+		{
+			name: "escaping",
+			point: metrics.Point{Name: "Disk Used,Total", Value: 1, Timestamp: timestamp, Dimensions: []metrics.Dimension{
+				{Name: "Mount Point", Value: "/a=b,c d"},
+			}},
+			expected: `Disk\ Used\,Total,Mount\ Point=/a\=b\,c\ d value=1 1535796000000000000` + "\n",
+			written:  true,
+		},
+		{
+			name: "empty dimension dropped",
+			point: metrics.Point{Name: "Up", Value: 1, Timestamp: timestamp, Dimensions: []metrics.Dimension{
+				{Name: "Target", Value: ""},
+			}},
+			expected: "Up value=1 1535796000000000000\n",
+			written:  true,
+		},
+		{
+			name:     "without timestamp",
+			point:    metrics.Point{Name: "Up", Value: 1e21},
+			expected: "Up value=1e+21\n",
+			written:  true,
+		},
+		{
+			name:     "NaN",
+			point:    metrics.Point{Name: "Up", Value: math.NaN(), Timestamp: timestamp},
+			expected: "",
+		},
+		{
+			name:     "infinite",
+			point:    metrics.Point{Name: "Up", Value: math.Inf(-1), Timestamp: timestamp},
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			written, err := writeInfluxLine(&b, &tc.point)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.written, written)
+			assert.Equal(t, tc.expected, b.String())
+		})
+	}
+}
+
+type influxRequest struct {
+	path     string
+	query    map[string]string
+	header   http.Header
+	username string
+	password string
+	body     string
+}
+
+func newInfluxServer(t *testing.T, status int) (*httptest.Server, *[]influxRequest) {
+	requests := &[]influxRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			assert.NoError(t, err)
+			body = gz
+		}
+		b, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+
+		query := map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		username, password, _ := r.BasicAuth()
+		*requests = append(*requests, influxRequest{
+			path:     r.URL.Path,
+			query:    query,
+			header:   r.Header,
+			username: username,
+			password: password,
+			body:     string(b),
+		})
+
+		w.WriteHeader(status)
+		if status != http.StatusNoContent {
+			w.Write([]byte(`{"error":"database not found"}`))
+		}
+	}))
+	return server, requests
+}
+
+func createInfluxData(numDataPoints int) metrics.Data {
+	data := metrics.Data{}
+	for i := 0; i < numDataPoints; i++ {
+		data = append(data, &metrics.Point{Name: "Point", Value: float64(i), Timestamp: time.Unix(int64(i), 0)})
+	}
+	return data
+}
+
+func TestInfluxDBSink_Publish(t *testing.T) {
+	t.Run("v1", func(t *testing.T) {
+		server, requests := newInfluxServer(t, http.StatusNoContent)
+		defer server.Close()
+
+		s := InfluxDBSink{URL: server.URL + "/", Database: "telegraf", Username: "user", Password: "secret"}
+		err := s.Publish(createInfluxData(2))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "influxdb", s.Name())
+		assert.Len(t, *requests, 1)
+		r := (*requests)[0]
+		assert.Equal(t, "/write", r.path)
+		assert.Equal(t, map[string]string{"db": "telegraf", "precision": "ns"}, r.query)
+		assert.Equal(t, "user", r.username)
+		assert.Equal(t, "secret", r.password)
+		assert.Equal(t, "Point value=0 0\nPoint value=1 1000000000\n", r.body)
+	})
+
+	t.Run("v2", func(t *testing.T) {
+		server, requests := newInfluxServer(t, http.StatusNoContent)
+		defer server.Close()
+
+		s := InfluxDBSink{URL: server.URL, Org: "org", Bucket: "bucket", Token: "token"}
+		err := s.Publish(createInfluxData(1))
+
+		assert.NoError(t, err)
+		assert.Len(t, *requests, 1)
+		r := (*requests)[0]
+		assert.Equal(t, "/api/v2/write", r.path)
+		assert.Equal(t, map[string]string{"org": "org", "bucket": "bucket", "precision": "ns"}, r.query)
+		assert.Equal(t, "Token token", r.header.Get("Authorization"))
+		assert.Equal(t, "Point value=0 0\n", r.body)
+	})
+
+	t.Run("batches with gzip", func(t *testing.T) {
+		server, requests := newInfluxServer(t, http.StatusNoContent)
+		defer server.Close()
+
+		s := InfluxDBSink{URL: server.URL, Database: "telegraf", BatchSize: 2, Gzip: true}
+		err := s.Publish(createInfluxData(5))
+
+		assert.NoError(t, err)
+		assert.Len(t, *requests, 3)
+		lines := []string{}
+		for _, r := range *requests {
+			assert.Equal(t, "gzip", r.header.Get("Content-Encoding"))
+			lines = append(lines, strings.Split(strings.TrimSpace(r.body), "\n")...)
+		}
+		assert.Len(t, lines, 5)
+	})
+
+	t.Run("only invalid values", func(t *testing.T) {
+		server, requests := newInfluxServer(t, http.StatusNoContent)
+		defer server.Close()
+
+		s := InfluxDBSink{URL: server.URL, Database: "telegraf"}
+		err := s.Publish(metrics.Data{&metrics.Point{Name: "Point", Value: math.NaN()}})
+
+		assert.NoError(t, err)
+		assert.Len(t, *requests, 0)
+	})
+
+	t.Run("failed write", func(t *testing.T) {
+		server, _ := newInfluxServer(t, http.StatusNotFound)
+		defer server.Close()
+
+		s := InfluxDBSink{URL: server.URL, Database: "missing"}
+		err := s.Publish(createInfluxData(1))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+		assert.Contains(t, err.Error(), "database not found")
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		s := InfluxDBSink{URL: "http://127.0.0.1:1", Database: "telegraf", Timeout: time.Second}
+		err := s.Publish(createInfluxData(1))
+
+		assert.Error(t, err)
+	})
+}
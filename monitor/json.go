@@ -0,0 +1,208 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultJSONOutput     = "stdout"
+	defaultJSONMaxBackups = 5
+)
+
+// jsonPoint is the JSON representation of a data point, compatible with the JSON lines read by the exec metric
+type jsonPoint struct {
+	Name       string            `json:"name"`
+	Value      float64           `json:"value"`
+	Unit       string            `json:"unit"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Dimensions map[string]string `json:"dimensions"`
+	Cumulative bool              `json:"cumulative,omitempty"`
+}
+
+// encodeJSONLines encodes every data point as a JSON object on its own line.
+// NaN and infinite values, not representable in JSON, are skipped.
+func encodeJSONLines(data metrics.Data) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	for _, p := range data {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+
+		dimensions := make(map[string]string, len(p.Dimensions))
+		for _, d := range p.Dimensions {
+			dimensions[d.Name] = d.Value
+		}
+		err := encoder.Encode(jsonPoint{
+			Name:       p.Name,
+			Value:      p.Value,
+			Unit:       string(p.Unit),
+			Timestamp:  p.Timestamp,
+			Dimensions: dimensions,
+			Cumulative: p.Cumulative,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// JSONSink writes every data point as a JSON object on its own line, to stdout or to the file at Output.
+// The file is rotated when writing would make it larger than MaxSize bytes or when it has been open for
+// longer than MaxAge, renaming it to <Output>.1 and the previous rotated files to <Output>.2 and so on,
+// keeping at most MaxBackups rotated files. Rotation is disabled when MaxSize and MaxAge are zero.
+type JSONSink struct {
+	Output     string
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+
+	mu     sync.Mutex
+	stdout io.Writer
+	file   *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+}
+
+// NewJSONSink creates a sink writing JSON lines to the output, rotating the file at the given size and age
+func NewJSONSink(output string, maxSize int64, maxAge time.Duration, maxBackups int) *JSONSink {
+	return &JSONSink{
+		Output:     output,
+		MaxSize:    maxSize,
+		MaxAge:     maxAge,
+		MaxBackups: maxBackups,
+		stdout:     os.Stdout,
+		now:        time.Now,
+	}
+}
+
+// Name of the JSON sink
+func (s *JSONSink) Name() string {
+	return "json"
+}
+
+func (s *JSONSink) output() string {
+	if s.Output != "" {
+		return s.Output
+	}
+	return defaultJSONOutput
+}
+
+func (s *JSONSink) maxBackups() int {
+	if s.MaxBackups > 0 {
+		return s.MaxBackups
+	}
+	return defaultJSONMaxBackups
+}
+
+// open the output file, appending to it if it already exists
+func (s *JSONSink) open() error {
+	file, err := os.OpenFile(s.output(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open json file [%s]", s.output())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrapf(err, "failed to open json file [%s]", s.output())
+	}
+
+	s.file, s.size, s.opened = file, info.Size(), s.now()
+	return nil
+}
+
+// shouldRotate returns true if the file must be rotated before writing size bytes
+func (s *JSONSink) shouldRotate(size int) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.MaxSize > 0 && s.size+int64(size) > s.MaxSize {
+		return true
+	}
+	return s.MaxAge > 0 && s.now().Sub(s.opened) >= s.MaxAge
+}
+
+// rotate closes the file and shifts it and the previous rotated files by one, removing the oldest
+func (s *JSONSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		log.Warnf("failed to close json file [%s]: %s", s.output(), err)
+	}
+	s.file = nil
+
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", s.output(), i) }
+	os.Remove(backup(s.maxBackups()))
+	for i := s.maxBackups() - 1; i > 0; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to rotate json file [%s]", backup(i))
+		}
+	}
+	if err := os.Rename(s.output(), backup(1)); err != nil {
+		return errors.Wrapf(err, "failed to rotate json file [%s]", s.output())
+	}
+	log.Debugf("rotated json file [%s]", s.output())
+	return nil
+}
+
+// Publish writes the data points as JSON lines, rotating the file first if needed
+func (s *JSONSink) Publish(data metrics.Data) error {
+	payload, err := encodeJSONLines(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode data as json")
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.output() == "stdout" {
+		_, err := s.stdout.Write(payload)
+		return errors.Wrap(err, "failed to write json to stdout")
+	}
+
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.shouldRotate(len(payload)) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(payload)
+	s.size += int64(n)
+	return errors.Wrapf(err, "failed to write json file [%s]", s.output())
+}
+
+// Close closes the output file
+func (s *JSONSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
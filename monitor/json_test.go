@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+var jsonTimestamp = time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+
+func jsonData(name string) metrics.Data {
+	return metrics.Data{{Name: name, Value: 1, Unit: metrics.UnitCount, Timestamp: jsonTimestamp}}
+}
+
+func TestEncodeJSONLines(t *testing.T) {
+	output, err := encodeJSONLines(metrics.Data{
+		{Name: "CPUUtilization", Value: 12.5, Unit: metrics.UnitPercent, Timestamp: jsonTimestamp, Dimensions: []metrics.Dimension{{Name: "Host", Value: "a"}}},
+		{Name: "Invalid", Value: math.Inf(1), Timestamp: jsonTimestamp},
+		{Name: "IOReadBytes", Value: 1024, Unit: metrics.UnitBytes, Cumulative: true, Timestamp: jsonTimestamp},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"CPUUtilization","value":12.5,"unit":"Percent","timestamp":"2018-09-01T10:00:00Z","dimensions":{"Host":"a"}}
+{"name":"IOReadBytes","value":1024,"unit":"Bytes","timestamp":"2018-09-01T10:00:00Z","dimensions":{},"cumulative":true}
+`, string(output))
+}
+
+func readJSONFile(t *testing.T, path string) []string {
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		names = append(names, strings.Split(line, `"`)[3])
+	}
+	return names
+}
+
+func TestJSONSink_Publish(t *testing.T) {
+	t.Run("stdout", func(t *testing.T) {
+		var b bytes.Buffer
+		s := NewJSONSink("", 0, 0, 0)
+		s.stdout = &b
+
+		assert.Equal(t, "json", s.Name())
+		assert.NoError(t, s.Publish(jsonData("a")))
+		assert.NoError(t, s.Publish(metrics.Data{}))
+		assert.Equal(t, `{"name":"a","value":1,"unit":"Count","timestamp":"2018-09-01T10:00:00Z","dimensions":{}}`+"\n", b.String())
+	})
+
+	t.Run("file appends", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "json")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "metrics.json")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(`{"name":"existing"}`+"\n"), 0644))
+
+		s := NewJSONSink(path, 0, 0, 0)
+		assert.NoError(t, s.Publish(jsonData("a")))
+		assert.NoError(t, s.Publish(jsonData("b")))
+		assert.NoError(t, s.Close())
+		assert.NoError(t, s.Close())
+
+		assert.Equal(t, []string{"existing", "a", "b"}, readJSONFile(t, path))
+	})
+
+	t.Run("rotates by size", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "json")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "metrics.json")
+		line, _ := encodeJSONLines(jsonData("a"))
+		s := NewJSONSink(path, int64(len(line)*2), 0, 2)
+		defer s.Close()
+
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+			assert.NoError(t, s.Publish(jsonData(name)))
+		}
+
+		assert.Equal(t, []string{"g"}, readJSONFile(t, path))
+		assert.Equal(t, []string{"e", "f"}, readJSONFile(t, path+".1"))
+		assert.Equal(t, []string{"c", "d"}, readJSONFile(t, path+".2"))
+		_, err = os.Stat(path + ".3")
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("rotates by age", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "json")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		now := jsonTimestamp
+		path := filepath.Join(dir, "metrics.json")
+		s := NewJSONSink(path, 0, time.Hour, 0)
+		s.now = func() time.Time { return now }
+		defer s.Close()
+
+		assert.NoError(t, s.Publish(jsonData("a")))
+		now = now.Add(30 * time.Minute)
+		assert.NoError(t, s.Publish(jsonData("b")))
+		now = now.Add(30 * time.Minute)
+		assert.NoError(t, s.Publish(jsonData("c")))
+
+		assert.Equal(t, []string{"c"}, readJSONFile(t, path))
+		assert.Equal(t, []string{"a", "b"}, readJSONFile(t, path+".1"))
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		s := NewJSONSink("/nonexistent/metrics.json", 0, 0, 0)
+		err := s.Publish(jsonData("a"))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to open json file")
+	})
+}
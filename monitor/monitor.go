@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"context"
+	"io"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -78,15 +79,21 @@ func PublishDataToCloudWatch(data metrics.Data, namespace string, client cloudwa
 }
 
 // Monitor gathers the data from the requested metrics, adds extra dimensions if requests and publish
-// them to CloudWatch under the provided namespace using the given client.
-func Monitor(metrics []metrics.Metric, extraDimensions []metrics.Dimension, namespace string, client cloudwatchiface.CloudWatchAPI) {
+// them to all the given sinks
+func Monitor(metrics []metrics.Metric, extraDimensions []metrics.Dimension, sinks []Sink) {
 	data := GatherData(metrics)
 	data.AddDimensions(extraDimensions...)
-	err := PublishDataToCloudWatch(data, namespace, client)
-	if err != nil {
-		log.Errorf("failed to publish data to cloudwatch: %s", err)
-	} else {
-		log.Infof("published %d data points to namespace [%s]", len(data), namespace)
+	PublishData(data, sinks)
+}
+
+// closeMetrics releases the resources held by long lived metrics, e.g. open connections
+func closeMetrics(collectedMetrics []metrics.Metric) {
+	for _, metric := range collectedMetrics {
+		if closer, ok := metric.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Warnf("failed to close metric [%s]: %s", metric.Name(), err)
+			}
+		}
 	}
 }
 
@@ -98,8 +105,18 @@ func Run(ctx context.Context, c Config) error {
 	}
 
 	c.logConfig()
+
+	sinks, err := c.getRequestedSinks()
+	if err != nil {
+		return errors.Wrap(err, "failed to start sinks")
+	}
+	defer closeSinks(sinks)
+
 	log.Info("starting monitoring")
-	Monitor(c.getRequestedMetrics(), c.getExtraDimensions(), c.Namespace, c.Client)
+	requestedMetrics := c.getRequestedMetrics()
+	defer closeMetrics(requestedMetrics)
+
+	Monitor(requestedMetrics, c.getExtraDimensions(), sinks)
 	if !c.Once {
 		var wg sync.WaitGroup
 		wg.Add(1)
@@ -110,7 +127,7 @@ func Run(ctx context.Context, c Config) error {
 			for {
 				select {
 				case <-ticker.C:
-					Monitor(c.getRequestedMetrics(), c.getExtraDimensions(), c.Namespace, c.Client)
+					Monitor(requestedMetrics, c.getExtraDimensions(), sinks)
 				case <-ctx.Done():
 					log.Info("stopping monitoring")
 					return
@@ -211,12 +211,12 @@ func TestMonitor(t *testing.T) {
 			MetricData: expected,
 		}).Return(&cloudwatch.PutMetricDataOutput{}, nil).Once()
 
-		Monitor([]metrics.Metric{m}, []metrics.Dimension{extraDimension}, namespace, mockClient)
+		Monitor([]metrics.Metric{m}, []metrics.Dimension{extraDimension}, []Sink{CloudWatchSink{Namespace: namespace, Client: mockClient}})
 
 		m.AssertExpectations(t)
 		mockClient.AssertExpectations(t)
 
-		assert.Contains(t, hook.LastEntry().Message, namespace)
+		assert.Contains(t, hook.LastEntry().Message, "published 2 data points to sink [cloudwatch]")
 	})
 
 	t.Run("failed put logs error", func(t *testing.T) {
@@ -237,13 +237,55 @@ func TestMonitor(t *testing.T) {
 			MetricData: expected,
 		}).Return(&cloudwatch.PutMetricDataOutput{}, errors.New("an error")).Once()
 
-		Monitor([]metrics.Metric{m}, []metrics.Dimension{extraDimension}, namespace, mockClient)
+		Monitor([]metrics.Metric{m}, []metrics.Dimension{extraDimension}, []Sink{CloudWatchSink{Namespace: namespace, Client: mockClient}})
 
 		m.AssertExpectations(t)
 		mockClient.AssertExpectations(t)
 
 		assert.Contains(t, hook.LastEntry().Message, "an error")
 	})
+
+	t.Run("publishes the data with the extra dimensions", func(t *testing.T) {
+		data, _ := createDataAndExpectedCWInput(numDataPoints, timestamp, namespace)
+		m := new(mockMetric)
+		m.On("Gather").Return(metrics.Data(data), nil)
+
+		var published metrics.Data
+		sink := &mockSink{name: "sink"}
+		sink.On("Publish", mock.Anything).Run(func(args mock.Arguments) {
+			published = args.Get(0).(metrics.Data)
+		}).Return(nil).Once()
+
+		Monitor([]metrics.Metric{m}, []metrics.Dimension{extraDimension}, []Sink{sink})
+
+		m.AssertExpectations(t)
+		sink.AssertExpectations(t)
+		assert.Len(t, published, numDataPoints)
+		assert.Contains(t, published[0].Dimensions, extraDimension)
+	})
+}
+
+type mockClosingMetric struct {
+	mockMetric
+}
+
+func (m *mockClosingMetric) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func TestCloseMetrics(t *testing.T) {
+	m := new(mockMetric)
+	c := new(mockClosingMetric)
+	c.On("Close").Return(nil).Once()
+	f := new(mockClosingMetric)
+	f.On("Close").Return(errors.New("an error")).Once()
+
+	closeMetrics([]metrics.Metric{m, c, f})
+
+	m.AssertNotCalled(t, "Close")
+	c.AssertExpectations(t)
+	f.AssertExpectations(t)
 }
 
 func TestRun(t *testing.T) {
@@ -0,0 +1,317 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOTLPTimeout = 10 * time.Second
+	otlpMetricsPath    = "/v1/metrics"
+	// otlpCumulative is the AGGREGATION_TEMPORALITY_CUMULATIVE value of the OTLP AggregationTemporality
+	otlpCumulative = 2
+	// otlpHostDimension is the dimension identifying the host moved to the host.name resource attribute
+	otlpHostDimension = "Host"
+)
+
+// otlpUnits maps the CloudWatch units to UCUM units. Multiples of bytes and bits are powers of 1024.
+var otlpUnits = map[metrics.Unit]string{
+	metrics.UnitSeconds:         "s",
+	metrics.UnitMicroseconds:    "us",
+	metrics.UnitMilliseconds:    "ms",
+	metrics.UnitBytes:           "By",
+	metrics.UnitKilobytes:       "KiBy",
+	metrics.UnitMegabytes:       "MiBy",
+	metrics.UnitGigabytes:       "GiBy",
+	metrics.UnitTerabytes:       "TiBy",
+	metrics.UnitBits:            "bit",
+	metrics.UnitKilobits:        "Kibit",
+	metrics.UnitMegabits:        "Mibit",
+	metrics.UnitGigabits:        "Gibit",
+	metrics.UnitTerabits:        "Tibit",
+	metrics.UnitPercent:         "%",
+	metrics.UnitCount:           "1",
+	metrics.UnitBytesSecond:     "By/s",
+	metrics.UnitKilobytesSecond: "KiBy/s",
+	metrics.UnitMegabytesSecond: "MiBy/s",
+	metrics.UnitGigabytesSecond: "GiBy/s",
+	metrics.UnitTerabytesSecond: "TiBy/s",
+	metrics.UnitBitsSecond:      "bit/s",
+	metrics.UnitKilobitsSecond:  "Kibit/s",
+	metrics.UnitMegabitsSecond:  "Mibit/s",
+	metrics.UnitGigabitsSecond:  "Gibit/s",
+	metrics.UnitTerabitsSecond:  "Tibit/s",
+	metrics.UnitCountSecond:     "1/s",
+	metrics.UnitNone:            "",
+}
+
+// The following types are the JSON encoding of the OTLP ExportMetricsServiceRequest
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Unit  string     `json:"unit,omitempty"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpExportRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+// otlpAttributes converts the map into OTLP attributes sorted by key
+func otlpAttributes(attributes map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: attributes[k]}})
+	}
+	return kvs
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpStart is the start of a cumulative series
+type otlpStart struct {
+	time  time.Time
+	value float64
+}
+
+// OTLPSink sends the data to the OTLP/HTTP endpoint of an OpenTelemetry Collector at URL, using the
+// JSON encoding. Dimensions are sent as attributes of the data points except for the Host dimension
+// sent as the host.name attribute of the resource, together with ResourceAttributes. Cumulative points
+// are sent as monotonic cumulative sums and all other points as gauges.
+type OTLPSink struct {
+	URL                string
+	Headers            map[string]string
+	ResourceAttributes map[string]string
+	Timeout            time.Duration
+
+	mu     sync.Mutex
+	starts map[string]otlpStart
+}
+
+// NewOTLPSink creates a sink sending the data to the OTLP endpoint with the given headers and resource attributes
+func NewOTLPSink(url string, headers, resourceAttributes map[string]string) *OTLPSink {
+	return &OTLPSink{URL: url, Headers: headers, ResourceAttributes: resourceAttributes, starts: map[string]otlpStart{}}
+}
+
+// Name of the OTLP sink
+func (s *OTLPSink) Name() string {
+	return "otlp"
+}
+
+func (s *OTLPSink) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultOTLPTimeout
+}
+
+// endpoint returns the URL the data is sent to, adding the /v1/metrics path if the URL has no path
+func (s *OTLPSink) endpoint() string {
+	u, err := url.Parse(s.URL)
+	if err != nil || strings.Trim(u.Path, "/") != "" {
+		return s.URL
+	}
+	u.Path = otlpMetricsPath
+	return u.String()
+}
+
+// resource returns the attributes of the resource of the host
+func (s *OTLPSink) resource(host string) []otlpKeyValue {
+	attributes := map[string]string{"service.name": "cwmonitor"}
+	for k, v := range s.ResourceAttributes {
+		attributes[k] = v
+	}
+	if host != "" {
+		attributes["host.name"] = host
+	}
+	return otlpAttributes(attributes)
+}
+
+// start returns the start time of the cumulative series, i.e. the time it was first seen or the time
+// it was last reset, a counter being reset when its value decreases
+func (s *OTLPSink) start(key string, point *metrics.Point) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, ok := s.starts[key]
+	if !ok || point.Value < start.value {
+		start.time = point.Timestamp
+	}
+	start.value = point.Value
+	s.starts[key] = start
+	return start.time
+}
+
+// request builds the export request for the data, grouping the points by host into resources and by
+// name, unit and kind into metrics. NaN and infinite values are skipped.
+func (s *OTLPSink) request(data metrics.Data) otlpExportRequest {
+	hosts := []string{}
+	metricsByHost := map[string][]*otlpMetric{}
+	metricsByKey := map[string]*otlpMetric{}
+	for _, p := range data {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+
+		host := ""
+		attributes := map[string]string{}
+		for _, d := range p.Dimensions {
+			if d.Name == otlpHostDimension {
+				host = d.Value
+				continue
+			}
+			attributes[d.Name] = d.Value
+		}
+		if _, ok := metricsByHost[host]; !ok {
+			hosts = append(hosts, host)
+			metricsByHost[host] = []*otlpMetric{}
+		}
+
+		key := strings.Join([]string{host, p.Name, string(p.Unit), strconv.FormatBool(p.Cumulative)}, "|")
+		metric, ok := metricsByKey[key]
+		if !ok {
+			metric = &otlpMetric{Name: p.Name, Unit: otlpUnits[p.Unit]}
+			if p.Cumulative {
+				metric.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+			} else {
+				metric.Gauge = &otlpGauge{}
+			}
+			metricsByKey[key] = metric
+			metricsByHost[host] = append(metricsByHost[host], metric)
+		}
+
+		point := otlpNumberDataPoint{
+			Attributes:   otlpAttributes(attributes),
+			TimeUnixNano: otlpTime(p.Timestamp),
+			AsDouble:     p.Value,
+		}
+		if p.Cumulative {
+			seriesKey := key
+			for _, kv := range point.Attributes {
+				seriesKey += "|" + kv.Key + "=" + kv.Value.StringValue
+			}
+			point.StartTimeUnixNano = otlpTime(s.start(seriesKey, p))
+			metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+		} else {
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+		}
+	}
+
+	request := otlpExportRequest{ResourceMetrics: make([]otlpResourceMetrics, 0, len(hosts))}
+	for _, host := range hosts {
+		scopeMetrics := otlpScopeMetrics{Scope: otlpScope{Name: "cwmonitor"}, Metrics: []otlpMetric{}}
+		for _, m := range metricsByHost[host] {
+			scopeMetrics.Metrics = append(scopeMetrics.Metrics, *m)
+		}
+		request.ResourceMetrics = append(request.ResourceMetrics, otlpResourceMetrics{
+			Resource:     otlpResource{Attributes: s.resource(host)},
+			ScopeMetrics: []otlpScopeMetrics{scopeMetrics},
+		})
+	}
+	return request
+}
+
+// Publish sends the data to the OTLP endpoint in a single export request
+func (s *OTLPSink) Publish(data metrics.Data) error {
+	log.Debug("exporting data points to otlp")
+	request := s.request(data)
+	if len(request.ResourceMetrics) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode otlp export request")
+	}
+
+	httpRequest, err := http.NewRequest(http.MethodPost, s.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create otlp export request")
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	for k, v := range s.Headers {
+		httpRequest.Header.Set(k, v)
+	}
+
+	client := http.Client{Timeout: s.timeout()}
+	response, err := client.Do(httpRequest)
+	if err != nil {
+		return errors.Wrap(err, "failed to export data to otlp")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return errors.Errorf("failed to export data to otlp: unexpected status [%s]: %s",
+			response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
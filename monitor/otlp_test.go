@@ -0,0 +1,136 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+var otlpTimestamp = time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+
+func TestOTLPSink_endpoint(t *testing.T) {
+	assert.Equal(t, "http://localhost:4318/v1/metrics", NewOTLPSink("http://localhost:4318", nil, nil).endpoint())
+	assert.Equal(t, "http://localhost:4318/v1/metrics", NewOTLPSink("http://localhost:4318/", nil, nil).endpoint())
+	assert.Equal(t, "https://otlp.example.com/custom/metrics", NewOTLPSink("https://otlp.example.com/custom/metrics", nil, nil).endpoint())
+}
+
+func TestOTLPSink_request(t *testing.T) {
+	s := NewOTLPSink("http://localhost:4318", nil, map[string]string{"deployment.environment": "production"})
+	data := metrics.Data{
+		{Name: "CPUUtilization", Value: 12.5, Unit: metrics.UnitPercent, Timestamp: otlpTimestamp, Dimensions: []metrics.Dimension{
+			{Name: "Container", Value: "web"}, {Name: "Host", Value: "a"},
+		}},
+		{Name: "IOReadBytes", Value: 1024, Unit: metrics.UnitBytes, Cumulative: true, Timestamp: otlpTimestamp, Dimensions: []metrics.Dimension{
+			{Name: "Container", Value: "web"}, {Name: "Host", Value: "a"},
+		}},
+		{Name: "CPUUtilization", Value: 50, Unit: metrics.UnitPercent, Timestamp: otlpTimestamp, Dimensions: []metrics.Dimension{
+			{Name: "Container", Value: "db"}, {Name: "Host", Value: "a"},
+		}},
+		{Name: "Up", Value: 1, Unit: metrics.UnitNone, Timestamp: otlpTimestamp},
+		{Name: "Invalid", Value: math.NaN(), Timestamp: otlpTimestamp},
+	}
+
+	body, err := json.Marshal(s.request(data))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"resourceMetrics": [
+		{
+			"resource": {"attributes": [
+				{"key": "deployment.environment", "value": {"stringValue": "production"}},
+				{"key": "host.name", "value": {"stringValue": "a"}},
+				{"key": "service.name", "value": {"stringValue": "cwmonitor"}}
+			]},
+			"scopeMetrics": [{"scope": {"name": "cwmonitor"}, "metrics": [
+				{"name": "CPUUtilization", "unit": "%", "gauge": {"dataPoints": [
+					{"attributes": [{"key": "Container", "value": {"stringValue": "web"}}], "timeUnixNano": "1535796000000000000", "asDouble": 12.5},
+					{"attributes": [{"key": "Container", "value": {"stringValue": "db"}}], "timeUnixNano": "1535796000000000000", "asDouble": 50}
+				]}},
+				{"name": "IOReadBytes", "unit": "By", "sum": {"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": [
+					{"attributes": [{"key": "Container", "value": {"stringValue": "web"}}], "startTimeUnixNano": "1535796000000000000", "timeUnixNano": "1535796000000000000", "asDouble": 1024}
+				]}}
+			]}]
+		},
+		{
+			"resource": {"attributes": [
+				{"key": "deployment.environment", "value": {"stringValue": "production"}},
+				{"key": "service.name", "value": {"stringValue": "cwmonitor"}}
+			]},
+			"scopeMetrics": [{"scope": {"name": "cwmonitor"}, "metrics": [
+				{"name": "Up", "gauge": {"dataPoints": [{"timeUnixNano": "1535796000000000000", "asDouble": 1}]}}
+			]}]
+		}
+	]}`, string(body))
+}
+
+func TestOTLPSink_start(t *testing.T) {
+	s := NewOTLPSink("http://localhost:4318", nil, nil)
+	point := func(value float64, timestamp time.Time) *metrics.Point {
+		return &metrics.Point{Name: "IOReadBytes", Value: value, Cumulative: true, Timestamp: timestamp}
+	}
+
+	assert.Equal(t, otlpTimestamp, s.start("key", point(10, otlpTimestamp)))
+	assert.Equal(t, otlpTimestamp, s.start("key", point(20, otlpTimestamp.Add(time.Minute))))
+	// a decreasing counter has been reset
+	assert.Equal(t, otlpTimestamp.Add(2*time.Minute), s.start("key", point(5, otlpTimestamp.Add(2*time.Minute))))
+	assert.Equal(t, otlpTimestamp.Add(3*time.Minute), s.start("other", point(5, otlpTimestamp.Add(3*time.Minute))))
+}
+
+func TestOTLPSink_Publish(t *testing.T) {
+	t.Run("successful", func(t *testing.T) {
+		var request otlpExportRequest
+		var header http.Header
+		var path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, header = r.URL.Path, r.Header
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.NoError(t, json.Unmarshal(body, &request))
+			w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		s := NewOTLPSink(server.URL, map[string]string{"Authorization": "Bearer token"}, nil)
+		err := s.Publish(metrics.Data{{Name: "Up", Value: 1, Timestamp: otlpTimestamp}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "otlp", s.Name())
+		assert.Equal(t, "/v1/metrics", path)
+		assert.Equal(t, "application/json", header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", header.Get("Authorization"))
+		assert.Len(t, request.ResourceMetrics, 1)
+		assert.Equal(t, "Up", request.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Name)
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		s := NewOTLPSink("http://127.0.0.1:1", nil, nil)
+		assert.NoError(t, s.Publish(metrics.Data{}))
+	})
+
+	t.Run("failed export", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message": "invalid metric"}`))
+		}))
+		defer server.Close()
+
+		s := NewOTLPSink(server.URL, nil, nil)
+		err := s.Publish(metrics.Data{{Name: "Up", Value: 1, Timestamp: otlpTimestamp}})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "400")
+		assert.Contains(t, err.Error(), "invalid metric")
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		s := NewOTLPSink("http://127.0.0.1:1", nil, nil)
+		s.Timeout = time.Second
+		err := s.Publish(metrics.Data{{Name: "Up", Value: 1, Timestamp: otlpTimestamp}})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,247 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// prometheusUnit is the suffix of the name of a Prometheus metric for a unit together with the scale
+// converting the value to the base unit
+type prometheusUnit struct {
+	suffix string
+	scale  float64
+}
+
+var prometheusUnits = map[metrics.Unit]prometheusUnit{
+	metrics.UnitSeconds:         {"seconds", 1},
+	metrics.UnitMilliseconds:    {"seconds", 1e-3},
+	metrics.UnitMicroseconds:    {"seconds", 1e-6},
+	metrics.UnitBytes:           {"bytes", 1},
+	metrics.UnitKilobytes:       {"bytes", 1 << 10},
+	metrics.UnitMegabytes:       {"bytes", 1 << 20},
+	metrics.UnitGigabytes:       {"bytes", 1 << 30},
+	metrics.UnitTerabytes:       {"bytes", 1 << 40},
+	metrics.UnitBits:            {"bits", 1},
+	metrics.UnitKilobits:        {"bits", 1 << 10},
+	metrics.UnitMegabits:        {"bits", 1 << 20},
+	metrics.UnitGigabits:        {"bits", 1 << 30},
+	metrics.UnitTerabits:        {"bits", 1 << 40},
+	metrics.UnitPercent:         {"percent", 1},
+	metrics.UnitCount:           {"", 1},
+	metrics.UnitBytesSecond:     {"bytes_per_second", 1},
+	metrics.UnitKilobytesSecond: {"bytes_per_second", 1 << 10},
+	metrics.UnitMegabytesSecond: {"bytes_per_second", 1 << 20},
+	metrics.UnitGigabytesSecond: {"bytes_per_second", 1 << 30},
+	metrics.UnitTerabytesSecond: {"bytes_per_second", 1 << 40},
+	metrics.UnitBitsSecond:      {"bits_per_second", 1},
+	metrics.UnitKilobitsSecond:  {"bits_per_second", 1 << 10},
+	metrics.UnitMegabitsSecond:  {"bits_per_second", 1 << 20},
+	metrics.UnitGigabitsSecond:  {"bits_per_second", 1 << 30},
+	metrics.UnitTerabitsSecond:  {"bits_per_second", 1 << 40},
+	metrics.UnitCountSecond:     {"per_second", 1},
+	metrics.UnitNone:            {"", 1},
+}
+
+// toSnakeCase converts a CamelCase name, e.g. CPUUtilization, into a valid Prometheus name in snake case,
+// e.g. cpu_utilization. Characters not allowed in Prometheus names are replaced by underscores.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				b.WriteRune('_')
+			}
+		}
+
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	snake := b.String()
+	for strings.Contains(snake, "__") {
+		snake = strings.Replace(snake, "__", "_", -1)
+	}
+	snake = strings.Trim(snake, "_")
+	if snake != "" && unicode.IsDigit(rune(snake[0])) {
+		snake = "_" + snake
+	}
+	return snake
+}
+
+// prometheusName returns the name of the Prometheus metric for a data point and the scale of its value.
+// Cumulative data points are named as counters with the _total suffix.
+func prometheusName(namespace string, point *metrics.Point) (string, float64) {
+	unit, ok := prometheusUnits[point.Unit]
+	if !ok {
+		unit = prometheusUnit{"", 1}
+	}
+
+	name := toSnakeCase(point.Name)
+	if prefix := toSnakeCase(namespace); prefix != "" {
+		name = prefix + "_" + name
+	}
+	if unit.suffix != "" && !strings.HasSuffix(name, "_"+unit.suffix) {
+		name += "_" + unit.suffix
+	}
+	if point.Cumulative && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+	return name, unit.scale
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusLabels formats the dimensions of a data point as Prometheus labels sorted by name
+func prometheusLabels(dimensions []metrics.Dimension) string {
+	labels := make([]string, 0, len(dimensions))
+	for _, d := range dimensions {
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, toSnakeCase(d.Name), prometheusLabelEscaper.Replace(d.Value)))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	sort.Strings(labels)
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// WritePrometheus writes the data in the Prometheus text exposition format. Every data point is written as
+// a gauge named after the snake case version of the namespace and of the name of the point followed by the
+// base unit, e.g. cw_monitor_cpu_utilization_percent, with the dimensions of the point as labels.
+// Cumulative data points, counting since their source started, are written as counters with the _total
+// suffix instead, e.g. cw_monitor_throttled_periods_total.
+// Values are converted to the base units, e.g. milliseconds to seconds.
+func WritePrometheus(w io.Writer, namespace string, data metrics.Data) error {
+	series := map[string]map[string]float64{}
+	types := map[string]string{}
+	for _, p := range data {
+		name, scale := prometheusName(namespace, p)
+		if _, ok := series[name]; !ok {
+			series[name] = map[string]float64{}
+			types[name] = "gauge"
+			if p.Cumulative {
+				types[name] = "counter"
+			}
+		}
+		series[name][prometheusLabels(p.Dimensions)] = p.Value * scale
+	}
+
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "# TYPE %s %s\n", name, types[name]); err != nil {
+			return err
+		}
+
+		labels := make([]string, 0, len(series[name]))
+		for l := range series[name] {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			value := strconv.FormatFloat(series[name][l], 'g', -1, 64)
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", name, l, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrometheusExporter is a sink serving the latest published data in the Prometheus text exposition format
+type PrometheusExporter struct {
+	Namespace string
+
+	mu     sync.RWMutex
+	data   metrics.Data
+	server *http.Server
+}
+
+// NewPrometheusExporter creates an exporter naming the metrics after the given namespace
+func NewPrometheusExporter(namespace string) *PrometheusExporter {
+	return &PrometheusExporter{Namespace: namespace, data: metrics.Data{}}
+}
+
+// Name of the Prometheus sink
+func (e *PrometheusExporter) Name() string {
+	return "prometheus"
+}
+
+// Publish replaces the data served by the exporter
+func (e *PrometheusExporter) Publish(data metrics.Data) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.data = data
+	return nil
+}
+
+// ServeHTTP writes the latest data in the Prometheus text exposition format
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	data := e.data
+	e.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := WritePrometheus(w, e.Namespace, data); err != nil {
+		log.Warnf("failed to write prometheus metrics: %s", err)
+	}
+}
+
+// Serve starts serving the exporter on the /metrics path of the given address until the returned
+// server is shut down or error if the address cannot be listened on
+func (e *PrometheusExporter) Serve(address string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen for prometheus scrapes on [%s]", address)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	server := &http.Server{Handler: mux}
+	e.mu.Lock()
+	e.server = server
+	e.mu.Unlock()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Errorf("prometheus exporter stopped: %s", err)
+		}
+	}()
+
+	log.Infof("serving prometheus metrics on [%s/metrics]", listener.Addr())
+	return server, nil
+}
+
+// Close stops the server started by Serve, if any
+func (e *PrometheusExporter) Close() error {
+	e.mu.Lock()
+	server := e.server
+	e.server = nil
+	e.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Close()
+}
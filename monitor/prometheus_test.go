@@ -0,0 +1,164 @@
+package monitor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "CPUUtilization", expected: "cpu_utilization"},
+		{input: "MemoryUtilization", expected: "memory_utilization"},
+		{input: "IOReadBytes", expected: "io_read_bytes"},
+		{input: "CWMonitor", expected: "cw_monitor"},
+		{input: "Host", expected: "host"},
+		{input: "latency.p90", expected: "latency_p90"},
+		{input: "http_requests_total", expected: "http_requests_total"},
+		{input: "Disk Used (%)", expected: "disk_used"},
+		{input: "90th", expected: "_90th"},
+		{input: "", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, toSnakeCase(tc.input))
+		})
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		unit     metrics.Unit
+		expected string
+		scale    float64
+	}{
+		{name: "CPUUtilization", unit: metrics.UnitPercent, expected: "cw_cpu_utilization_percent", scale: 1},
+		{name: "ResponseTime", unit: metrics.UnitMilliseconds, expected: "cw_response_time_seconds", scale: 1e-3},
+		{name: "MemoryUsed", unit: metrics.UnitKilobytes, expected: "cw_memory_used_bytes", scale: 1024},
+		{name: "NetworkRxBytes", unit: metrics.UnitBytes, expected: "cw_network_rx_bytes", scale: 1},
+		{name: "Requests", unit: metrics.UnitCountSecond, expected: "cw_requests_per_second", scale: 1},
+		{name: "PidsCurrent", unit: metrics.UnitCount, expected: "cw_pids_current", scale: 1},
+		{name: "StatusCode", unit: metrics.UnitNone, expected: "cw_status_code", scale: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := metrics.NewDataPoint(tc.name, 1, tc.unit)
+			name, scale := prometheusName("CW", &p)
+			assert.Equal(t, tc.expected, name)
+			assert.Equal(t, tc.scale, scale)
+		})
+	}
+	t.Run("cumulative", func(t *testing.T) {
+		p := metrics.NewCumulativeDataPoint("ThrottledTime", 1, metrics.UnitSeconds)
+		name, _ := prometheusName("CW", &p)
+		assert.Equal(t, "cw_throttled_time_seconds_total", name)
+
+		p = metrics.NewCumulativeDataPoint("requests_total", 1, metrics.UnitCount)
+		name, _ = prometheusName("CW", &p)
+		assert.Equal(t, "cw_requests_total", name)
+	})
+}
+
+func TestWritePrometheus(t *testing.T) {
+	cpu1 := metrics.NewDataPoint("CPUUtilization", 12.5, metrics.UnitPercent, metrics.Dimension{Name: "Host", Value: "a"})
+	cpu2 := metrics.NewDataPoint("CPUUtilization", 50, metrics.UnitPercent,
+		metrics.Dimension{Name: "Host", Value: "b"}, metrics.Dimension{Name: "Container", Value: `web "1"`})
+	responseTime := metrics.NewDataPoint("ResponseTime", 250, metrics.UnitMilliseconds)
+	throttledPeriods := metrics.NewCumulativeDataPoint("ThrottledPeriods", 30, metrics.UnitCount)
+
+	var b bytes.Buffer
+	err := WritePrometheus(&b, "CWMonitor", metrics.Data{&responseTime, &cpu2, &cpu1, &throttledPeriods})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `# TYPE cw_monitor_cpu_utilization_percent gauge
+cw_monitor_cpu_utilization_percent{container="web \"1\"",host="b"} 50
+cw_monitor_cpu_utilization_percent{host="a"} 12.5
+# TYPE cw_monitor_response_time_seconds gauge
+cw_monitor_response_time_seconds 0.25
+# TYPE cw_monitor_throttled_periods_total counter
+cw_monitor_throttled_periods_total 30
+`, b.String())
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	t.Run("serves the latest data", func(t *testing.T) {
+		e := NewPrometheusExporter("CWMonitor")
+		server := httptest.NewServer(e)
+		defer server.Close()
+
+		body := func() string {
+			response, err := http.Get(server.URL)
+			assert.NoError(t, err)
+			defer response.Body.Close()
+			assert.Contains(t, response.Header.Get("Content-Type"), "text/plain")
+			b, _ := ioutil.ReadAll(response.Body)
+			return string(b)
+		}
+		assert.Equal(t, "", body())
+
+		first := metrics.NewDataPoint("MemoryUtilization", 10, metrics.UnitPercent)
+		assert.NoError(t, e.Publish(metrics.Data{&first}))
+		assert.Equal(t, "# TYPE cw_monitor_memory_utilization_percent gauge\ncw_monitor_memory_utilization_percent 10\n", body())
+
+		second := metrics.NewDataPoint("SwapUtilization", 20, metrics.UnitPercent)
+		assert.NoError(t, e.Publish(metrics.Data{&second}))
+		assert.Equal(t, "# TYPE cw_monitor_swap_utilization_percent gauge\ncw_monitor_swap_utilization_percent 20\n", body())
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		e := NewPrometheusExporter("CWMonitor")
+		p := metrics.NewDataPoint("MemoryUtilization", 10, metrics.UnitPercent)
+
+		assert.Equal(t, "prometheus", e.Name())
+		assert.NoError(t, e.Publish(metrics.Data{&p}))
+
+		var b bytes.Buffer
+		assert.NoError(t, WritePrometheus(&b, e.Namespace, e.data))
+		assert.Contains(t, b.String(), "cw_monitor_memory_utilization_percent 10")
+	})
+
+	t.Run("serve", func(t *testing.T) {
+		e := NewPrometheusExporter("CWMonitor")
+		server, err := e.Serve("127.0.0.1:0")
+		assert.NoError(t, err)
+		assert.NoError(t, e.Close())
+		assert.Equal(t, http.ErrServerClosed, server.ListenAndServe())
+		assert.NoError(t, e.Close())
+	})
+
+	t.Run("serve on invalid address", func(t *testing.T) {
+		e := NewPrometheusExporter("CWMonitor")
+		_, err := e.Serve("invalid:address:1")
+		assert.Error(t, err)
+	})
+}
+
+func TestRun_prometheusOnly(t *testing.T) {
+	mockClient := new(mockCloudWatchClient)
+
+	c := Config{
+		Namespace:        "test",
+		Interval:         time.Second,
+		HostId:           "test",
+		Metrics:          "memory",
+		Once:             true,
+		Sinks:            "prometheus",
+		PrometheusListen: "127.0.0.1:0",
+		Client:           mockClient,
+	}
+
+	assert.NoError(t, Run(nil, c))
+	mockClient.AssertNotCalled(t, "PutMetricData")
+}
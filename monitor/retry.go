@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHTTPBackoff = time.Second
+	defaultHTTPTimeout = 10 * time.Second
+)
+
+// httpRetry sends HTTP requests retrying the failed ones with an exponential backoff
+type httpRetry struct {
+	Retries int
+	Backoff time.Duration
+	Timeout time.Duration
+}
+
+func (r httpRetry) backoff() time.Duration {
+	if r.Backoff > 0 {
+		return r.Backoff
+	}
+	return defaultHTTPBackoff
+}
+
+func (r httpRetry) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+	return defaultHTTPTimeout
+}
+
+// send the request built by newRequest, called for every attempt, until it succeeds with a 2xx status.
+// Network errors and 429 and 5xx statuses are retried up to Retries times, waiting Backoff before the
+// first retry and doubling the wait before every following retry, while other statuses fail immediately.
+func (r httpRetry) send(name string, newRequest func() (*http.Request, error)) error {
+	client := http.Client{Timeout: r.timeout()}
+	wait := r.backoff()
+	for attempt := 0; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create %s request", name)
+		}
+
+		retryable, err := r.do(&client, name, request)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= r.Retries {
+			return err
+		}
+
+		log.Debugf("retrying %s request in %s: %s", name, wait, err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// do sends the request returning error if it fails and whether it can be retried
+func (r httpRetry) do(client *http.Client, name string, request *http.Request) (bool, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return true, errors.Wrapf(err, "failed to send %s request", name)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, response.Body)
+		return false, nil
+	}
+
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+	retryable := response.StatusCode == http.StatusTooManyRequests || response.StatusCode/100 == 5
+	return retryable, errors.Errorf("failed to send %s request: unexpected status [%s]: %s",
+		name, response.Status, strings.TrimSpace(string(message)))
+}
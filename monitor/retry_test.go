@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPRetry_send(t *testing.T) {
+	server := func(statuses ...int) (*httptest.Server, *int) {
+		requests := 0
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := statuses[len(statuses)-1]
+			if requests < len(statuses) {
+				status = statuses[requests]
+			}
+			requests++
+			w.WriteHeader(status)
+			w.Write([]byte("message"))
+		})), &requests
+	}
+	newRequest := func(url string) func() (*http.Request, error) {
+		return func() (*http.Request, error) { return http.NewRequest(http.MethodPost, url, nil) }
+	}
+	r := httpRetry{Retries: 2, Backoff: time.Millisecond}
+
+	t.Run("retries failed requests", func(t *testing.T) {
+		s, requests := server(http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent)
+		defer s.Close()
+
+		assert.NoError(t, r.send("test", newRequest(s.URL)))
+		assert.Equal(t, 3, *requests)
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		s, requests := server(http.StatusInternalServerError)
+		defer s.Close()
+
+		err := r.send("test", newRequest(s.URL))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "500 Internal Server Error")
+		assert.Contains(t, err.Error(), "message")
+		assert.Equal(t, 3, *requests)
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		s, requests := server(http.StatusBadRequest)
+		defer s.Close()
+
+		assert.Error(t, r.send("test", newRequest(s.URL)))
+		assert.Equal(t, 1, *requests)
+	})
+
+	t.Run("retries network errors", func(t *testing.T) {
+		s, _ := server(http.StatusOK)
+		url := s.URL
+		s.Close()
+
+		err := r.send("test", newRequest(url))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to send test request")
+	})
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/dedalusj/cwmonitor/util"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Sink is a backend the gathered data is published to
+type Sink interface {
+	Name() string
+	Publish(data metrics.Data) error
+}
+
+// CloudWatchSink publishes the data to CloudWatch under the namespace using the client
+type CloudWatchSink struct {
+	Namespace string
+	Client    cloudwatchiface.CloudWatchAPI
+}
+
+// Name of the CloudWatch sink
+func (s CloudWatchSink) Name() string {
+	return "cloudwatch"
+}
+
+// Publish the data to CloudWatch
+func (s CloudWatchSink) Publish(data metrics.Data) error {
+	return PublishDataToCloudWatch(data, s.Namespace, s.Client)
+}
+
+// PublishData publishes the data to all the sinks in parallel. The failure of a sink does not
+// prevent the data from being published to the other sinks and every sink logs its outcome.
+// It returns the errors of the failed sinks.
+func PublishData(data metrics.Data, sinks []Sink) error {
+	errs := make([]error, len(sinks))
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		wg.Add(1)
+		go func(i int, sink Sink) {
+			defer wg.Done()
+			if err := sink.Publish(data); err != nil {
+				log.Errorf("failed to publish data to sink [%s]: %s", sink.Name(), err)
+				errs[i] = errors.Wrapf(err, "sink [%s]", sink.Name())
+				return
+			}
+			log.Infof("published %d data points to sink [%s]", len(data), sink.Name())
+		}(i, sink)
+	}
+	wg.Wait()
+
+	multierror := util.MultiError{}
+	for _, err := range errs {
+		multierror.Add(err)
+	}
+	return multierror.ErrorOrNil()
+}
+
+// closeSinks releases the resources held by the sinks, e.g. open connections or listeners
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Warnf("failed to close sink [%s]: %s", sink.Name(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSink struct {
+	mock.Mock
+	name string
+}
+
+func (m *mockSink) Name() string {
+	return m.name
+}
+
+func (m *mockSink) Publish(data metrics.Data) error {
+	args := m.Called(data)
+	return args.Error(0)
+}
+
+type mockClosingSink struct {
+	mockSink
+}
+
+func (m *mockClosingSink) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func TestCloudWatchSink(t *testing.T) {
+	data, expected := createDataAndExpectedCWInput(2, time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC), "namespace")
+
+	mockClient := new(mockCloudWatchClient)
+	mockClient.On("PutMetricData", &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String("namespace"),
+		MetricData: expected,
+	}).Return(&cloudwatch.PutMetricDataOutput{}, nil).Once()
+
+	s := CloudWatchSink{Namespace: "namespace", Client: mockClient}
+
+	assert.Equal(t, "cloudwatch", s.Name())
+	assert.NoError(t, s.Publish(data))
+	mockClient.AssertExpectations(t)
+}
+
+func TestPublishData(t *testing.T) {
+	t.Run("all sinks", func(t *testing.T) {
+		hook := test.NewGlobal()
+		data := metrics.Data{&metrics.Point{}}
+
+		first := &mockSink{name: "first"}
+		first.On("Publish", data).Return(nil).Once()
+		second := &mockSink{name: "second"}
+		second.On("Publish", data).Return(nil).Once()
+
+		err := PublishData(data, []Sink{first, second})
+
+		assert.NoError(t, err)
+		first.AssertExpectations(t)
+		second.AssertExpectations(t)
+		assert.Len(t, hook.AllEntries(), 2)
+	})
+
+	t.Run("failing sink does not affect the others", func(t *testing.T) {
+		hook := test.NewGlobal()
+		data := metrics.Data{&metrics.Point{}}
+
+		failing := &mockSink{name: "failing"}
+		failing.On("Publish", data).Return(errors.New("an error")).Once()
+		working := &mockSink{name: "working"}
+		working.On("Publish", data).Return(nil).Once()
+
+		err := PublishData(data, []Sink{failing, working})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failing")
+		assert.Contains(t, err.Error(), "an error")
+		assert.NotContains(t, err.Error(), "working")
+		failing.AssertExpectations(t)
+		working.AssertExpectations(t)
+
+		messages := make([]string, len(hook.AllEntries()))
+		for i, e := range hook.AllEntries() {
+			messages[i] = e.Message
+		}
+		logOutput := strings.Join(messages, "\n")
+		assert.Contains(t, logOutput, "failed to publish data to sink [failing]: an error")
+		assert.Contains(t, logOutput, "published 1 data points to sink [working]")
+	})
+
+	t.Run("no sinks", func(t *testing.T) {
+		assert.NoError(t, PublishData(metrics.Data{}, []Sink{}))
+	})
+}
+
+func TestCloseSinks(t *testing.T) {
+	s := &mockSink{name: "sink"}
+	c := &mockClosingSink{mockSink{name: "closing"}}
+	c.On("Close").Return(nil).Once()
+	f := &mockClosingSink{mockSink{name: "failing"}}
+	f.On("Close").Return(errors.New("an error")).Once()
+
+	closeSinks([]Sink{s, c, f})
+
+	s.AssertNotCalled(t, "Close")
+	c.AssertExpectations(t)
+	f.AssertExpectations(t)
+}
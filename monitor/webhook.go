@@ -0,0 +1,167 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/dedalusj/cwmonitor/util"
+	"github.com/pkg/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookMethod   = http.MethodPost
+	defaultWebhookTemplate = `{"namespace":{{json .Namespace}},"points":{{json .Points}}}`
+)
+
+// webhookFuncs are the functions available to the templates of the webhook body
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// webhookData is the data the template of the webhook body is executed with
+type webhookData struct {
+	Namespace string
+	Points    []jsonPoint
+}
+
+// ParseWebhookTemplate parses the template of the body of the webhook requests. A template starting
+// with @ is read from the file following it, e.g. @/etc/cwmonitor/webhook.tmpl. The template is
+// executed with the Namespace and the Points, with the name, value, unit, timestamp and dimensions
+// of every data point, and the json function encoding a value as JSON.
+func ParseWebhookTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+	if strings.HasPrefix(text, "@") {
+		content, err := ioutil.ReadFile(text[1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read webhook template [%s]", text[1:])
+		}
+		text = string(content)
+	}
+
+	t, err := template.New("webhook").Funcs(webhookFuncs).Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid webhook template")
+	}
+	return t, nil
+}
+
+// WebhookSink sends the data to URL with a body built from Template, by default a JSON object with the
+// namespace and the points, and the given Headers. Requests authenticate with the bearer Token, if set,
+// or with Username and Password. Points are sent in batches of at most BatchSize points, all the points
+// in a single request if BatchSize is zero.
+type WebhookSink struct {
+	httpRetry
+
+	URL       string
+	Method    string
+	Template  *template.Template
+	Headers   map[string]string
+	Username  string
+	Password  string
+	Token     string
+	Namespace string
+	BatchSize int
+}
+
+// NewWebhookSink creates a sink sending the data to the URL with a body built from the template text,
+// or error if the template is invalid
+func NewWebhookSink(url, templateText, namespace string) (*WebhookSink, error) {
+	t, err := ParseWebhookTemplate(templateText)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookSink{URL: url, Template: t, Namespace: namespace, Headers: map[string]string{}}, nil
+}
+
+// Name of the webhook sink
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) method() string {
+	if s.Method != "" {
+		return strings.ToUpper(s.Method)
+	}
+	return defaultWebhookMethod
+}
+
+// body executes the template with the batch, skipping NaN and infinite values
+func (s *WebhookSink) body(batch metrics.Data) ([]byte, int, error) {
+	points := make([]jsonPoint, 0, len(batch))
+	for _, p := range batch {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		dimensions := make(map[string]string, len(p.Dimensions))
+		for _, d := range p.Dimensions {
+			dimensions[d.Name] = d.Value
+		}
+		points = append(points, jsonPoint{
+			Name:       p.Name,
+			Value:      p.Value,
+			Unit:       string(p.Unit),
+			Timestamp:  p.Timestamp,
+			Dimensions: dimensions,
+			Cumulative: p.Cumulative,
+		})
+	}
+
+	var body bytes.Buffer
+	if err := s.Template.Execute(&body, webhookData{Namespace: s.Namespace, Points: points}); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to execute webhook template")
+	}
+	return body.Bytes(), len(points), nil
+}
+
+// Publish sends the data to the webhook in batches, retrying the failed requests
+func (s *WebhookSink) Publish(data metrics.Data) error {
+	log.Debug("sending data points to webhook")
+	batches := []metrics.Data{data}
+	if s.BatchSize > 0 {
+		batches = data.Batch(s.BatchSize)
+	}
+
+	multierror := util.MultiError{}
+	for _, batch := range batches {
+		body, points, err := s.body(batch)
+		if err != nil {
+			multierror.Add(err)
+			continue
+		}
+		if points == 0 {
+			continue
+		}
+
+		multierror.Add(s.send("webhook", func() (*http.Request, error) {
+			request, err := http.NewRequest(s.method(), s.URL, bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			request.Header.Set("Content-Type", "application/json")
+			for k, v := range s.Headers {
+				request.Header.Set(k, v)
+			}
+			switch {
+			case s.Token != "":
+				request.Header.Set("Authorization", "Bearer "+s.Token)
+			case s.Username != "":
+				request.SetBasicAuth(s.Username, s.Password)
+			}
+			return request, nil
+		}))
+	}
+	return multierror.ErrorOrNil()
+}
@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/cwmonitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+var webhookTimestamp = time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC)
+
+func TestParseWebhookTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "webhook.tmpl")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{{.Namespace}}"), 0644))
+
+	tmpl, err := ParseWebhookTemplate("@" + path)
+	assert.NoError(t, err)
+	assert.Equal(t, "webhook", tmpl.Name())
+
+	_, err = ParseWebhookTemplate("@" + filepath.Join(dir, "missing.tmpl"))
+	assert.Error(t, err)
+
+	_, err = ParseWebhookTemplate("{{.Namespace")
+	assert.Error(t, err)
+}
+
+func TestWebhookSink_body(t *testing.T) {
+	data := metrics.Data{
+		{Name: "CPUUtilization", Value: 12.5, Unit: metrics.UnitPercent, Timestamp: webhookTimestamp, Dimensions: []metrics.Dimension{
+			{Name: "Host", Value: "a"},
+		}},
+	}
+
+	t.Run("default template", func(t *testing.T) {
+		s, err := NewWebhookSink("http://localhost:8080", "", "ns")
+		assert.NoError(t, err)
+
+		body, points, err := s.body(data)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, points)
+		assert.JSONEq(t, `{"namespace":"ns","points":[
+			{"name":"CPUUtilization","value":12.5,"unit":"Percent","timestamp":"2018-09-01T10:00:00Z","dimensions":{"Host":"a"}}
+		]}`, string(body))
+	})
+
+	t.Run("custom template", func(t *testing.T) {
+		s, err := NewWebhookSink("http://localhost:8080",
+			`{{range .Points}}{{.Name}}{host={{index .Dimensions "Host"}}} {{.Value}} {{.Timestamp.Unix}}{{end}}`, "ns")
+		assert.NoError(t, err)
+
+		body, _, err := s.body(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "CPUUtilization{host=a} 12.5 1535796000", string(body))
+	})
+}
+
+func TestWebhookSink_Publish(t *testing.T) {
+	bodies := []string{}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/metrics", r.URL.Path)
+		assert.Equal(t, "cwmonitor", r.Header.Get("X-Source"))
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	s, err := NewWebhookSink(server.URL+"/metrics", "{{range .Points}}{{.Name}}={{.Value}}{{end}}", "ns")
+	assert.NoError(t, err)
+	s.httpRetry = httpRetry{Retries: 1, Backoff: time.Millisecond}
+	s.Method = "put"
+	s.Headers = map[string]string{"X-Source": "cwmonitor"}
+	s.Username = "user"
+	s.Password = "pass"
+	s.BatchSize = 1
+
+	data := metrics.Data{
+		{Name: "Up", Value: 1, Timestamp: webhookTimestamp},
+		{Name: "Down", Value: 0, Timestamp: webhookTimestamp},
+	}
+	assert.NoError(t, s.Publish(data))
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, []string{"Up=1", "Down=0"}, bodies)
+}
+
+func TestWebhookSink_Publish_token(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	s, err := NewWebhookSink(server.URL, "", "ns")
+	assert.NoError(t, err)
+	s.Token = "token"
+
+	err = s.Publish(metrics.Data{{Name: "Up", Value: 1, Timestamp: webhookTimestamp}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "401 Unauthorized")
+}